@@ -1,18 +1,43 @@
 package main
 
 import (
+	"arch/config"
 	"arch/controller"
 	"arch/files/file_fs"
 	"arch/files/mock_fs"
+	"arch/keys"
 	"arch/lifecycle"
 	m "arch/model"
+	"arch/recorder"
 	"arch/renderer/tcell"
+	"arch/renderer/text"
+	webrenderer "arch/renderer/web"
 	"arch/stream"
+	w "arch/widgets"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+)
+
+// eventsCapacity bounds the controller's queue; scanners wait when it is full, while
+// input from the renderers is queued without waiting.
+const eventsCapacity = 4096
+
+var (
+	sim    = flag.Bool("sim", false, "simulate archives with scanning")
+	sim2   = flag.Bool("sim2", false, "simulate archives")
+	scen   = flag.String("scenario", "", "simulate archives described by a JSON scenario `file`")
+	record = flag.String("record", "", "record all events to a JSONL `file`")
+	replay = flag.String("replay", "", "replay events from a recorded JSONL `file` without a terminal")
+	speed  = flag.Float64("speed", 1, "replay speed factor, 0 replays without delays")
+	web    = flag.String("web", "", "serve the UI to browsers on `address` instead of the terminal, e.g. :8080 for localhost only")
 )
 
 func main() {
+	flag.Parse()
+
 	log.SetFlags(0)
 	logFile, err := os.Create("log.log")
 	if err == nil {
@@ -20,12 +45,24 @@ func main() {
 		defer logFile.Close()
 	}
 
+	var scenario *mock_fs.Scenario
+	if *scen != "" {
+		scenario, err = mock_fs.LoadScenario(*scen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load scenario: %v\n", err)
+			return
+		}
+	} else if *sim || *sim2 {
+		scenario = mock_fs.DefaultScenario()
+		scenario.Scan = *sim
+	}
+
 	var paths []m.Root
-	if len(os.Args) >= 1 && (os.Args[1] == "-sim" || os.Args[1] == "-sim2") {
-		paths = []m.Root{"origin", "copy 1", "copy 2"}
+	if scenario != nil {
+		paths = scenario.RootNames()
 	} else {
-		paths = make([]m.Root, len(os.Args)-1)
-		for i, path := range os.Args[1:] {
+		paths = make([]m.Root, flag.NArg())
+		for i, path := range flag.Args() {
 			path, err := file_fs.AbsPath(path)
 			paths[i] = m.Root(path)
 			if err != nil {
@@ -34,28 +71,119 @@ func main() {
 		}
 	}
 
-	lc := lifecycle.New()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return
+	}
+	registry, err := keys.New(cfg.Keys, commandActions(cfg.Commands)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid key bindings: %v\n", err)
+		return
+	}
 
-	events := stream.NewStream[m.Event]("contr")
-	renderer, err := tcell.NewRenderer(lc, events)
+	theme, err := loadTheme(cfg.Theme)
 	if err != nil {
-		log.Printf("Failed to open terminal: %#v", err)
+		fmt.Fprintf(os.Stderr, "Failed to load theme %q: %v\n", cfg.Theme, err)
 		return
 	}
+	w.SetTheme(theme)
+
+	if *replay != "" {
+		if err := replayEvents(*replay, *speed, cfg, registry); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to replay events: %v\n", err)
+		}
+		return
+	}
+
+	lc := lifecycle.New()
+
+	events := stream.NewBounded[m.Event]("contr", eventsCapacity, stream.Block)
+	if *record != "" {
+		rec, err := recorder.New(*record, paths)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to record events: %v\n", err)
+			return
+		}
+		events.Tap(rec.Record)
+		defer func() {
+			events.Tap(nil)
+			rec.Close()
+		}()
+	}
+
+	var renderer w.Renderer
+	if *web != "" {
+		var url string
+		renderer, url, err = webrenderer.NewRenderer(lc, events, registry, *web)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to serve on %s: %v\n", *web, err)
+			return
+		}
+		fmt.Printf("Serving on %s\n", url)
+	} else {
+		renderer, err = tcell.NewRenderer(lc, events, registry)
+		if err != nil {
+			log.Printf("Failed to open terminal: %#v", err)
+			return
+		}
+	}
 
 	var fs m.FS
 
-	if len(os.Args) >= 1 && os.Args[1] == "-sim" {
-		fs = mock_fs.NewFs(events)
-		mock_fs.Scan = true
-	} else if len(os.Args) >= 1 && os.Args[1] == "-sim2" {
-		fs = mock_fs.NewFs(events)
+	if scenario != nil {
+		fs = mock_fs.NewScenarioFs(events, scenario)
 	} else {
 		fs = file_fs.NewFs(events, lc)
 	}
 
-	controller.Run(fs, renderer, events, paths)
+	controller.Run(fs, renderer, events, paths, cfg, registry)
+	events.Close()
 
 	renderer.Quit()
 	lc.Stop()
 }
+
+func replayEvents(path string, speed float64, cfg *config.Config, registry *keys.Registry) error {
+	rec, err := recorder.Load(path)
+	if err != nil {
+		return err
+	}
+	events := stream.NewStream[m.Event]("replay")
+	renderer := text.NewRenderer()
+	go rec.Play(events, speed)
+	controller.Replay(recorder.ReplayFs(), renderer, events, rec.Roots, cfg, registry)
+	if screen := renderer.Screen(); screen != nil {
+		fmt.Print(text.Render(screen))
+	}
+	return nil
+}
+
+func loadTheme(name string) (w.Theme, error) {
+	if os.Getenv("NO_COLOR") != "" {
+		return w.MonochromeTheme(), nil
+	}
+	if name == "" {
+		return w.DefaultTheme(), nil
+	}
+	if theme, ok := w.Themes()[name]; ok {
+		return theme, nil
+	}
+	path, err := config.ThemePath(name)
+	if err != nil {
+		return w.Theme{}, err
+	}
+	return w.LoadTheme(path)
+}
+
+func commandActions(commands []config.Command) []keys.Action {
+	actions := make([]keys.Action, len(commands))
+	for i, command := range commands {
+		help := command.Help
+		if help == "" {
+			help = "Run " + strings.Join(command.Args, " ")
+		}
+		actions[i] = keys.Action{Name: command.Name, Help: help, Event: m.RunCommand{Name: command.Name}, Keys: command.Keys}
+	}
+	return actions
+}
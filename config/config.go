@@ -0,0 +1,71 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const fileName = "config.json"
+
+type Config struct {
+	Keys     map[string][]string `json:"keys"`
+	Theme    string              `json:"theme"`
+	KeepRule string              `json:"keepRule"`
+	Open     []string            `json:"open"`
+	Reveal   []string            `json:"reveal"`
+	Commands []Command           `json:"commands"`
+	MaxFPS   int                 `json:"maxFps"`
+}
+
+// Command is an external command run on the selected or marked files.
+// Args may contain the placeholders {path}, {root}, {hash} and {copies}. An argument
+// containing {copies} is repeated for every copy.
+type Command struct {
+	Name     string   `json:"name"`
+	Help     string   `json:"help"`
+	Keys     []string `json:"keys"`
+	Args     []string `json:"args"`
+	Terminal bool     `json:"terminal"`
+}
+
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "arch"), nil
+}
+
+func Load() (*Config, error) {
+	dir, err := Dir()
+	if err != nil {
+		return &Config{}, nil
+	}
+	return LoadFile(filepath.Join(dir, fileName))
+}
+
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func ThemePath(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "themes", name+".json"), nil
+}
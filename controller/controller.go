@@ -1,12 +1,19 @@
 package controller
 
 import (
+	"arch/config"
+	"arch/keys"
 	m "arch/model"
+	"arch/opener"
 	"arch/stream"
 	w "arch/widgets"
+	"context"
+	"path/filepath"
 	"time"
 )
 
+const defaultMaxFPS = 30
+
 type controller struct {
 	roots  []m.Root
 	origin m.Root
@@ -14,7 +21,13 @@ type controller struct {
 	archives        map[m.Root]*archive
 	folders         map[m.Path]*folder
 	files           map[m.Hash][]*m.File
+	byId            map[m.Id]*m.File
+	deleted         map[m.Id]*m.File
 	state           map[m.Hash]w.State
+	stateCounts     [w.Absent + 1]int
+	tree            *folderTree
+	touched         map[m.Hash]struct{}
+	inFlight        map[m.Hash]int
 	copySize        uint64
 	totalCopiedSize uint64
 	fileCopiedSize  uint64
@@ -26,9 +39,40 @@ type controller struct {
 	lastMouseEventTime time.Time
 	currentPath        m.Path
 	selectedIdx        int
+	search             *search
+	searchResults      *folder
+	problems           bool
+	problemsFolder     *folder
+	filter             w.StateFilter
+	failed             map[m.Id]bool
+	marked             map[m.Id]*w.File
+	dragAnchor         m.Id
+	batching           bool
+	batch              []m.FileCommand
+	keepRule           keepRule
+	keepRuleName       string
+	dialog             *dialog
+	pane               *w.PaneInfo
+	output             *w.PaneInfo
+	keys               *keys.Registry
+	opener             *opener.Opener
+	events             *stream.Stream[m.Event]
+	renderer           w.Renderer
+	commands           []config.Command
+	sortStore          *sortStore
+	showPresence       bool
+	showDetails        bool
+	detailsId          m.Id
+	detailsOffset      int
+	pinnedCopy         m.Id
+	sync               *folderSync
 
-	frames   int
-	prevTick time.Time
+	frames        int
+	prevTick      time.Time
+	lastFrame     time.Time
+	frameInterval time.Duration
+	dirty         bool
+	entriesDirty  bool
 
 	view w.View
 
@@ -55,50 +99,198 @@ type folder struct {
 	sortAscending []bool
 }
 
-func Run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root) {
+func Run(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root, cfg *config.Config, keys *keys.Registry) {
+	go ticker(events)
+	newController(renderer, events, roots, cfg, keys).run(fs)
+}
+
+// Replay runs the controller without its ticker, so that all events, including ticks,
+// come from a recording. Like run, it renders once per batch of events, which the
+// recording plays at its own pace. Events which launch programs are skipped, the
+// recording has their outcome.
+func Replay(fs m.FS, renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root, cfg *config.Config, keys *keys.Registry) {
+	c := newController(renderer, events, roots, cfg, keys)
+	c.scanArchives(fs)
+	defer c.closeArchives()
+	for !c.quit {
+		batch := events.Pull()
+		if len(batch) == 0 {
+			return
+		}
+		for _, event := range batch {
+			switch event.(type) {
+			case m.Open, m.Reveal, m.RunCommand:
+				continue
+			}
+			c.handleEvent(event)
+		}
+		c.render()
+	}
+}
+
+// run renders at most once per frame interval. While the screen is dirty it waits
+// for events only until the next frame is due, otherwise it sleeps until an event comes.
+func (c *controller) run(fs m.FS) {
+	c.scanArchives(fs)
+	defer c.closeArchives()
+	for !c.quit {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if c.dirty {
+			ctx, cancel = context.WithDeadline(ctx, c.lastFrame.Add(c.frameInterval))
+		}
+		batch, err := c.events.PullContext(ctx)
+		cancel()
+		if err == stream.ErrClosed {
+			return
+		}
+		for _, event := range batch {
+			c.handleEvent(event)
+		}
+		if time.Since(c.lastFrame) >= c.frameInterval {
+			c.render()
+		}
+	}
+}
+
+func newController(renderer w.Renderer, events *stream.Stream[m.Event], roots []m.Root, cfg *config.Config, keys *keys.Registry) *controller {
+	events.Coalesce(progressKey)
 	c := &controller{
-		roots:  roots,
-		origin: roots[0],
+		roots:    roots,
+		origin:   roots[0],
+		keys:     keys,
+		opener:   opener.New(cfg.Open, cfg.Reveal),
+		events:   events,
+		renderer: renderer,
+		commands: cfg.Commands,
+
+		frameInterval: frameInterval(cfg.MaxFPS),
 
 		archives: map[m.Root]*archive{},
 		folders:  map[m.Path]*folder{},
 		files:    map[m.Hash][]*m.File{},
 		state:    map[m.Hash]w.State{},
+		tree:     newFolderTree(),
+		touched:  map[m.Hash]struct{}{},
+		inFlight: map[m.Hash]int{},
+		deleted:  map[m.Id]*m.File{},
+		failed:   map[m.Id]bool{},
+		marked:   map[m.Id]*w.File{},
 	}
+	c.tree.presence = c.filePresence
+	c.setKeepRule(cfg.KeepRule)
+	if dir, err := config.Dir(); err == nil {
+		c.sortStore = loadSortStore(filepath.Join(dir, "sort.json"), c.origin)
+	}
+	return c
+}
 
-	go ticker(events)
+type hashingKey m.Root
 
-	for _, path := range roots {
+type copyingKey struct{}
+
+// progressKey lets the event stream merge progress events, only the latest value matters.
+func progressKey(event m.Event) (any, bool) {
+	switch event := event.(type) {
+	case m.HashingProgress:
+		return hashingKey(event.Root), true
+	case m.CopyingProgress:
+		return copyingKey{}, true
+	}
+	return nil, false
+}
+
+func frameInterval(maxFPS int) time.Duration {
+	if maxFPS <= 0 {
+		maxFPS = defaultMaxFPS
+	}
+	return time.Second / time.Duration(maxFPS)
+}
+
+// markDirty records what an event may have changed. Progress events and ticks only
+// change the progress numbers, so the entries of the current folder are kept as they are.
+func (c *controller) markDirty(event any) {
+	switch event.(type) {
+	case m.Debug:
+	case m.HashingProgress, m.CopyingProgress, m.Tick:
+		c.dirty = true
+	default:
+		c.dirty, c.entriesDirty = true, true
+	}
+}
+
+func (c *controller) scanArchives(fs m.FS) {
+	for _, path := range c.roots {
 		scanner := fs.NewArchiveScanner(path)
 		c.archives[path] = &archive{
 			scanner: scanner,
 		}
 		scanner.Send(m.ScanArchive{})
 	}
+}
 
-	for !c.quit {
-		for _, event := range events.Pull() {
-			c.handleEvent(event)
-		}
+func (c *controller) closeArchives() {
+	for _, archive := range c.archives {
+		archive.scanner.Close()
+	}
+}
 
-		c.frames++
-		screen := w.NewScreen(c.view.ScreenSize)
-		c.buildView().RootWidget().Render(screen, w.Position{X: 0, Y: 0}, w.Size(c.view.ScreenSize))
-		renderer.Push(screen)
+func (c *controller) render() {
+	if !c.dirty {
+		return
 	}
+	c.dirty = false
+	c.lastFrame = time.Now()
+	c.frames++
+	screen := w.NewScreen(c.view.ScreenSize)
+	c.buildView().RootWidget().Render(screen, w.Position{X: 0, Y: 0}, w.Size(c.view.ScreenSize))
+	c.renderer.Push(screen)
 }
 
 func (c *controller) currentFolder() *folder {
+	if c.search != nil && c.search.recursive {
+		if c.searchResults == nil {
+			c.searchResults = newFolder()
+		}
+		return c.searchResults
+	}
+	if c.problems {
+		if c.problemsFolder == nil {
+			c.problemsFolder = newFolder()
+			c.problemsFolder.sortColumn = w.SortByState
+		}
+		return c.problemsFolder
+	}
 	curFolder, ok := c.folders[c.currentPath]
 	if !ok {
-		curFolder = &folder{
-			sortAscending: []bool{true, false, false},
-		}
+		curFolder = newFolder()
+		c.sortStore.restore(c.currentPath, curFolder)
 		c.folders[c.currentPath] = curFolder
 	}
 	return curFolder
 }
 
+func newFolder() *folder {
+	sortAscending := make([]bool, w.SortColumns)
+	for column := range sortAscending {
+		sortAscending[column] = ascendingByDefault(w.SortColumn(column))
+	}
+	return &folder{sortAscending: sortAscending}
+}
+
+// ascendingByDefault sorts names alphabetically, and times, sizes, states
+// and copies with the largest first.
+func ascendingByDefault(column w.SortColumn) bool {
+	switch column {
+	case w.SortByName, w.SortByPath, w.SortByExtension:
+		return true
+	}
+	return false
+}
+
+func (c *controller) flatView() bool {
+	return c.problems || c.search != nil && c.search.recursive
+}
+
 func (c *controller) every(f func(entry *m.File)) {
 	for _, entries := range c.files {
 		for _, entry := range entries {
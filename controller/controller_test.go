@@ -0,0 +1,744 @@
+package controller
+
+import (
+	"arch/config"
+	"arch/files/mock_fs"
+	"arch/keys"
+	m "arch/model"
+	"arch/renderer/text"
+	"arch/stream"
+	w "arch/widgets"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden screens")
+
+var screenSize = m.ScreenSize{Width: 100, Height: 30}
+
+// The golden screens show times in UTC. The zone is set before any test starts
+// goroutines reading it, such as the ticker of Run.
+func init() {
+	time.Local = time.UTC
+}
+
+// harness runs the event loop of the controller like Run does, without the ticker,
+// and takes the screens from a text renderer.
+type harness struct {
+	t        *testing.T
+	c        *controller
+	events   *stream.Stream[m.Event]
+	renderer *probeRenderer
+	done     chan struct{}
+}
+
+// probeRenderer runs the probes of the harness on the controller goroutine, right after
+// a frame was pushed while no events were queued. Then the controller has handled all the
+// events pushed before the probe, and its state matches the screen.
+type probeRenderer struct {
+	*text.Renderer
+	events *stream.Stream[m.Event]
+	probes chan func()
+}
+
+func (r *probeRenderer) Push(screen *w.Screen) {
+	r.Renderer.Push(screen)
+	if len(r.probes) > 0 && r.events.Stats().Len == 0 {
+		(<-r.probes)()
+	}
+}
+
+func newHarness(t *testing.T) *harness {
+	events := stream.NewStream[m.Event]("test")
+	return newFsHarness(t, mock_fs.NewFs(events), events, []m.Root{"origin", "copy 1", "copy 2"}, &config.Config{})
+}
+
+// newFsHarness runs the controller on the roots of the file system, which pushes its
+// events to the stream, and waits until they are scanned.
+func newFsHarness(t *testing.T, fs m.FS, events *stream.Stream[m.Event], roots []m.Root, cfg *config.Config) *harness {
+	prevLog := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(prevLog) })
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	mock_fs.Delay = 0
+	w.SetTheme(w.DefaultTheme())
+
+	registry, err := keys.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxFPS == 0 {
+		cfg.MaxFPS = 1000
+	}
+	h := &harness{
+		t:        t,
+		events:   events,
+		renderer: &probeRenderer{Renderer: text.NewRenderer(), events: events, probes: make(chan func(), 1)},
+		done:     make(chan struct{}),
+	}
+	h.c = newController(h.renderer, events, roots, cfg, registry)
+	ordered := newOrderedFs(fs, roots, events)
+	go func() {
+		h.c.run(ordered)
+		close(h.done)
+	}()
+	t.Cleanup(func() {
+		events.Close()
+		h.wait()
+	})
+	h.send(screenSize)
+	return h
+}
+
+// orderedFs scans the roots one after another, so that files are always
+// reported to the controller in the same order.
+type orderedFs struct {
+	m.FS
+	roots   []m.Root
+	scanned map[m.Root]chan struct{}
+}
+
+type orderedScanner struct {
+	m.ArchiveScanner
+	after chan struct{}
+}
+
+func newOrderedFs(fs m.FS, roots []m.Root, events *stream.Stream[m.Event]) *orderedFs {
+	ordered := &orderedFs{FS: fs, roots: roots, scanned: map[m.Root]chan struct{}{}}
+	for _, root := range roots {
+		ordered.scanned[root] = make(chan struct{})
+	}
+	events.Tap(func(event m.Event) {
+		if event, ok := event.(m.ArchiveScanned); ok {
+			close(ordered.scanned[event.Root])
+		}
+	})
+	return ordered
+}
+
+func (fs *orderedFs) NewArchiveScanner(root m.Root) m.ArchiveScanner {
+	s := &orderedScanner{ArchiveScanner: fs.FS.NewArchiveScanner(root)}
+	for i := 1; i < len(fs.roots); i++ {
+		if fs.roots[i] == root {
+			s.after = fs.scanned[fs.roots[i-1]]
+		}
+	}
+	return s
+}
+
+func (s *orderedScanner) Send(cmd m.FileCommand) {
+	if _, ok := cmd.(m.ScanArchive); ok && s.after != nil {
+		go func() {
+			<-s.after
+			s.ArchiveScanner.Send(cmd)
+		}()
+		return
+	}
+	s.ArchiveScanner.Send(cmd)
+}
+
+// do runs the function on the controller goroutine once the events pushed so far
+// are handled, and renders the screen again afterwards.
+func (h *harness) do(f func()) {
+	h.t.Helper()
+	done := make(chan struct{})
+	h.renderer.probes <- func() {
+		f()
+		h.c.dirty, h.c.entriesDirty = true, true
+		close(done)
+	}
+	h.events.Push(screenSize)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("Timed out waiting for the controller")
+	}
+}
+
+// send pushes the events one by one, waiting after each of them until all
+// scanning and file operations are done.
+func (h *harness) send(events ...m.Event) {
+	h.t.Helper()
+	for _, event := range events {
+		h.events.Push(event)
+		h.settle()
+	}
+}
+
+func (h *harness) settle() {
+	h.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		settled := false
+		h.do(func() { settled = h.settled() })
+		if settled {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatal("Timed out waiting for the controller to settle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (h *harness) settled() bool {
+	if !h.c.archivesScanned || len(h.c.inFlight) > 0 {
+		return false
+	}
+	for _, state := range h.c.state {
+		if state == w.Pending {
+			return false
+		}
+	}
+	return true
+}
+
+// quit stops the event loop, which closes the scanners.
+func (h *harness) quit() {
+	h.events.Push(m.Quit{})
+	h.wait()
+}
+
+func (h *harness) wait() {
+	select {
+	case <-h.done:
+	case <-time.After(10 * time.Second):
+		h.t.Fatal("Timed out waiting for the event loop to return")
+	}
+}
+
+func (h *harness) golden(name string) {
+	h.t.Helper()
+	got := text.Render(h.renderer.Screen())
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if got != string(want) {
+		h.t.Errorf("Screen %q differs from golden file %s:\n%s", name, path, got)
+	}
+}
+
+func TestInitialScreen(t *testing.T) {
+	h := newHarness(t)
+	h.golden("initial")
+}
+
+func TestNavigation(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.SelectFirst{}, m.MoveSelection{Lines: 8}, m.Enter{})
+	h.golden("enter_folder")
+	h.send(m.Exit{}, m.ToggleHelp{})
+	h.golden("help")
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.SelectFirst{}, m.MoveSelection{Lines: 1}, m.Delete{})
+	h.golden("delete_dialog")
+	h.send(m.Cancel{})
+	h.golden("delete_cancelled")
+}
+
+func TestKeepAll(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.KeepAll{})
+	h.golden("keep_all_dialog")
+	h.send(m.DialogSelect{})
+	h.golden("keep_all_done")
+}
+
+func TestKeepAllSendsWhatTheDialogCounts(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.KeepAll{})
+	var lines []string
+	h.do(func() { lines = h.c.dialog.lines })
+	renames, deletes, copies := 0, 0, 0
+	h.events.Tap(func(event m.Event) {
+		switch event := event.(type) {
+		case m.FileRenamed:
+			renames++
+		case m.FileDeleted:
+			deletes++
+		case m.FileCopied:
+			copies += len(event.To)
+		}
+	})
+	h.send(m.DialogSelect{})
+	h.settle()
+	h.events.Tap(nil)
+	done := []string{fmt.Sprintf("Rename %d files.", renames), fmt.Sprintf("Delete %d files.", deletes)}
+	if lines[2] != done[0] || lines[3] != done[1] || !strings.HasPrefix(lines[1], fmt.Sprintf("Copy %d files ", copies)) {
+		t.Errorf("Dialog %q, but copied %d files and sent %q", lines, copies, done)
+	}
+}
+
+func TestKeepMarkedClearsMarks(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.MouseTarget{Command: m.SelectFile(testId("origin/qqq.txt"))}, m.ToggleMark{}, m.KeepOne{})
+	h.do(func() {
+		if h.c.dialog == nil {
+			t.Error("Expected a confirmation for keeping the marked entries")
+		}
+	})
+	h.send(m.DialogSelect{})
+	h.do(func() {
+		if len(h.c.marked) != 0 {
+			t.Errorf("Expected no marks after keeping, got %d", len(h.c.marked))
+		}
+	})
+}
+
+func TestCommandOutputWaitsForDialog(t *testing.T) {
+	h := newHarness(t)
+	h.send(m.KeepAll{}, m.CommandOutput{Title: "ls", Lines: []string{"exit status 0"}})
+	h.do(func() {
+		if h.c.dialog == nil || h.c.pane != nil {
+			t.Error("Expected the dialog to stay open until answered")
+		}
+	})
+	h.send(m.Cancel{})
+	h.do(func() {
+		if h.c.pane == nil || h.c.pane.Title != "ls" {
+			t.Errorf("Expected the command output after the dialog, got %v", h.c.pane)
+		}
+	})
+}
+
+func TestTerminalCommandCapturedWithoutTerminal(t *testing.T) {
+	events := stream.NewStream[m.Event]("test")
+	cfg := &config.Config{Commands: []config.Command{{Name: "echo", Args: []string{"echo", "{root}"}, Terminal: true}}}
+	h := newFsHarness(t, mock_fs.NewFs(events), events, []m.Root{"origin", "copy 1", "copy 2"}, cfg)
+	h.send(m.SelectFirst{}, m.RunCommand{Name: "echo"})
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var pane *w.PaneInfo
+		h.do(func() { pane = h.c.pane })
+		if pane != nil {
+			if want := []string{"$ echo origin", "origin", "exit status 0", ""}; !reflect.DeepEqual(pane.Rows, want) {
+				t.Errorf("Output %q, want %q", pane.Rows, want)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the command output")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExpandCopies(t *testing.T) {
+	p := placeholders{path: "origin/a b", copies: []string{"origin/a b", "copy/a b"}}
+	got := expandArgs([]string{"diff", "--file={copies}", "{path}"}, p)
+	want := []string{"diff", "--file=origin/a b", "--file=copy/a b", "origin/a b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestCommandTargets(t *testing.T) {
+	folder := &w.File{File: m.File{Id: m.Id{Root: "copy", Name: m.Name{Path: "a", Base: "b"}}}, Kind: w.FileFolder}
+	lost := &w.File{File: m.File{Id: testId("copy/c.txt"), Hash: m.Hash{1}}}
+	c := &controller{
+		roots:  []m.Root{"origin", "copy"},
+		origin: "origin",
+		files:  map[m.Hash][]*m.File{},
+		marked: map[m.Id]*w.File{folder.Id: folder, lost.Id: lost},
+	}
+	got := c.commandTargets(&config.Command{Name: "test", Args: []string{"{copies}", "{path}", "{root}", "{hash}"}})
+	want := [][]string{
+		{"origin/a/b", "copy/a/b", "origin/a/b", "origin", ""},
+		{"copy/c.txt", "copy", m.Hash{1}.String()},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commandTargets() = %q, want %q", got, want)
+	}
+	if got := c.commandTargets(&config.Command{Name: "test", Args: []string{"{copies}"}}); len(got) != 1 {
+		t.Errorf("commandTargets() = %q, want only the folder", got)
+	}
+}
+
+func TestRenderOnlyWhenDirty(t *testing.T) {
+	h := newHarness(t)
+	h.do(func() {
+		h.c.render()
+		frames := h.renderer.Frames()
+		h.c.handleEvent(m.Debug{})
+		h.c.render()
+		if got := h.renderer.Frames(); got != frames {
+			t.Errorf("Expected no frame for an unchanged screen, got %d new", got-frames)
+		}
+		first := h.c.view.Entries[0]
+		h.c.handleEvent(m.HashingProgress{Root: "origin", Hashed: 1})
+		h.c.render()
+		if got := h.renderer.Frames(); got != frames+1 {
+			t.Errorf("Expected one frame for progress, got %d new", got-frames)
+		}
+		if h.c.view.Entries[0] != first {
+			t.Error("Expected progress to keep the entries")
+		}
+	})
+}
+
+func TestRunReturnsOnClose(t *testing.T) {
+	registry, err := keys.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	events := stream.NewStream[m.Event]("test")
+	done := make(chan struct{})
+	go func() {
+		Run(mock_fs.NewFs(events), text.NewRenderer(), events, []m.Root{"origin", "copy 1", "copy 2"}, &config.Config{}, registry)
+		close(done)
+	}()
+	events.Push(screenSize)
+	events.Close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected Run to return once the events are closed")
+	}
+}
+
+// newKeepHarness has a duplicate with a shorter path outside of folder a/b, and a copy
+// in a/b of content which the origin has elsewhere.
+func newKeepHarness(t *testing.T) (*harness, m.FS) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{"roots": [
+		{"root": "origin", "files": {"a/b/x.txt": "dup", "y.txt": "dup", "c/o1.txt": "other", "c/o2.txt": "other"}},
+		{"root": "copy", "files": {"a/b/x.txt": "dup", "a/b/w.txt": "other"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	return newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{}), fs
+}
+
+func TestKeepRenamesDifferentContentsApart(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{"roots": [
+		{"root": "origin", "files": {"o.txt": "o"}},
+		{"root": "copy 1", "files": {"x.txt": "a"}},
+		{"root": "copy 2", "files": {"x.txt": "b"}},
+		{"root": "copy 3", "files": {"x.txt": "c"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.do(func() { h.c.keepFile(h.c.fileAt(testId("copy 1/x.txt"))) })
+	h.settle()
+	names := map[m.Name]m.Hash{}
+	for id, hash := range mock_fs.Files(fs) {
+		if other, ok := names[id.Name]; ok && other != hash {
+			t.Errorf("%s has different contents in different roots", id.Name)
+		}
+		names[id.Name] = hash
+	}
+}
+
+func TestKeepFolderKeepsItsOriginFiles(t *testing.T) {
+	h, fs := newKeepHarness(t)
+	h.do(func() { h.c.keepFolder("a/b") })
+	h.send(m.DialogSelect{})
+	files := mock_fs.Files(fs)
+	for _, name := range []string{"origin/a/b/x.txt", "copy/a/b/x.txt"} {
+		if _, ok := files[testId(name)]; !ok {
+			t.Errorf("%s was deleted", name)
+		}
+	}
+	if _, ok := files[testId("copy/a/b/w.txt")]; ok {
+		t.Error("copy/a/b/w.txt is not in the origin folder, but was kept")
+	}
+	if files[testId("copy/c/o1.txt")] != files[testId("origin/c/o1.txt")] {
+		t.Error("Expected copy/a/b/w.txt to be moved to the path of its origin file")
+	}
+}
+
+func TestKeepFolderSparesLastCopies(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{"roots": [
+		{"root": "origin", "files": {"a/o.txt": "o"}},
+		{"root": "copy 1", "files": {"a/o.txt": "o", "a/n.txt": "new", "a/m.txt": "more"}},
+		{"root": "copy 2", "files": {"a/o.txt": "o", "m.txt": "more"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.do(func() {
+		h.c.keepFolder("a")
+		want := []string{"Delete 1 files missing from the origin.", "Leave 1 files missing from the origin, they are the last copies."}
+		if lines := h.c.dialog.lines; !reflect.DeepEqual(lines[len(lines)-2:], want) {
+			t.Errorf("Dialog %q, want it to end with %q", lines, want)
+		}
+	})
+	h.send(m.DialogSelect{})
+	files := mock_fs.Files(fs)
+	if _, ok := files[testId("copy 1/a/n.txt")]; !ok {
+		t.Error("The last copy copy 1/a/n.txt was deleted")
+	}
+	if _, ok := files[testId("copy 1/a/m.txt")]; ok {
+		t.Error("copy 1/a/m.txt is missing from the origin folder, but was kept")
+	}
+}
+
+func TestKeepAllInFolderKeepsItsOriginFiles(t *testing.T) {
+	h, fs := newKeepHarness(t)
+	h.send(m.MouseTarget{Command: m.SelectFolder("a/b")}, m.KeepAll{}, m.DialogSelect{})
+	if _, ok := mock_fs.Files(fs)[testId("origin/a/b/x.txt")]; !ok {
+		t.Error("origin/a/b/x.txt was deleted")
+	}
+}
+
+func TestKeepAllKeepsContentAbsentFromOrigin(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{"roots": [
+		{"root": "origin", "files": {"a/o.txt": "o"}},
+		{"root": "copy", "files": {"a/o.txt": "o", "a/n.txt": "new"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.send(m.MouseTarget{Command: m.SelectFolder("a")}, m.KeepAll{}, m.DialogSelect{})
+	if _, ok := mock_fs.Files(fs)[testId("origin/a/n.txt")]; !ok {
+		t.Error("Expected a/n.txt to be copied to the origin")
+	}
+}
+
+func TestFailureClearedWhenRetrySucceeds(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{
+		"roots": [
+			{"root": "origin", "files": {"x.txt": "dup", "y.txt": "dup", "z.txt": "dup"}},
+			{"root": "copy", "files": {}}
+		],
+		"faults": [{"op": "delete", "path": "y.txt", "kind": "permission"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	h := newFsHarness(t, mock_fs.NewScenarioFs(events, scenario), events, scenario.RootNames(), &config.Config{})
+	h.send(m.Error{Id: testId("origin/z.txt")})
+	h.do(func() { h.c.keepFile(h.c.fileAt(testId("origin/x.txt"))) })
+	h.settle()
+	h.do(func() {
+		if !h.c.failed[testId("origin/y.txt")] {
+			t.Error("Expected the failed delete of y.txt to be kept")
+		}
+		if _, ok := h.c.failed[testId("origin/z.txt")]; ok {
+			t.Error("Expected the failure of z.txt to be cleared by deleting it")
+		}
+	})
+}
+
+func TestFoldMatchCountsRunesOfName(t *testing.T) {
+	for _, test := range []struct {
+		name, query string
+		want        w.Match
+	}{
+		{"Report.TXT", "port.t", w.Match{Start: 2, End: 8}},
+		{"İstanbul-Ärger", "ärger", w.Match{Start: 9, End: 14}},
+		{"ǅemal", "ǆ", w.Match{Start: 0, End: 1}},
+	} {
+		if got, ok := foldMatch(test.name, test.query); !ok || got != test.want {
+			t.Errorf("foldMatch(%q, %q) = %v, %v, want %v", test.name, test.query, got, ok, test.want)
+		}
+	}
+}
+
+func TestKeepRulesChooseDifferently(t *testing.T) {
+	old, recent := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	short := &m.File{Id: testId("origin/x.txt"), ModTime: old}
+	current := &m.File{Id: testId("origin/a/b/c/y.txt"), ModTime: old}
+	newest := &m.File{Id: testId("origin/q/r/s.txt"), ModTime: recent}
+	copies := []*m.File{{Id: testId("copy 1/a/b/z.txt"), ModTime: recent}, {Id: testId("copy 2/a.txt"), ModTime: recent.Add(time.Hour)}}
+	files := append([]*m.File{current, short, newest}, copies...)
+	for rule, want := range map[string]*m.File{"current-path": current, "shortest-path": short, "newest": newest} {
+		c := &controller{origin: "origin", currentPath: "a/b"}
+		c.setKeepRule(rule)
+		if got := c.keepWinner(files); got != want {
+			t.Errorf("Rule %q kept %v, want %v", rule, got.Id, want.Id)
+		}
+	}
+	for rule, want := range map[string]*m.File{"current-path": copies[0], "shortest-path": copies[1], "newest": copies[1]} {
+		c := &controller{origin: "origin", currentPath: "a/b"}
+		c.setKeepRule(rule)
+		if got := c.keepWinner(copies); got != want {
+			t.Errorf("Rule %q kept %v of content absent from the origin, want %v", rule, got.Id, want.Id)
+		}
+	}
+
+	clashing := &m.File{Id: testId("copy 1/8888"), Hash: m.Hash{1}}
+	free := &m.File{Id: testId("copy 1/9999"), Hash: m.Hash{1}}
+	other := &m.File{Id: testId("copy 2/8888"), Hash: m.Hash{2}}
+	c := &controller{origin: "origin", roots: []m.Root{"origin", "copy 1", "copy 2"}, files: map[m.Hash][]*m.File{
+		{1}: {clashing, free},
+		{2}: {other},
+	}}
+	c.setKeepRule("shortest-path")
+	if got := c.keepWinner(c.files[m.Hash{1}]); got != free {
+		t.Errorf("Kept %v, which renames other content", got.Id)
+	}
+}
+
+func TestFailedRenameAndDeleteAreListed(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{
+		"roots": [
+			{"root": "origin", "files": {"a.txt": "x", "d1.txt": "y", "d2.txt": "y"}},
+			{"root": "copy", "files": {"b.txt": "x"}}
+		],
+		"faults": [
+			{"op": "rename", "path": "b.txt", "kind": "permission"},
+			{"op": "rename", "path": "a.txt", "kind": "permission"},
+			{"op": "delete", "path": "d2.txt", "kind": "permission"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.do(func() { h.c.keepFile(h.c.fileAt(testId("copy/b.txt"))) })
+	h.settle()
+	h.do(func() { h.c.keepFile(h.c.fileAt(testId("origin/d1.txt"))) })
+	h.settle()
+	h.do(func() { h.c.filter = w.FilterFailed })
+
+	h.do(func() {
+		listed := map[m.Id]bool{}
+		for _, entry := range h.c.view.Entries {
+			listed[entry.Id] = true
+		}
+		for _, name := range []string{"origin/a.txt", "origin/d2.txt"} {
+			if !listed[testId(name)] {
+				t.Errorf("Expected %s under the failed filter, got %v", name, listed)
+			}
+		}
+		known := map[m.Id]m.Hash{}
+		h.c.every(func(file *m.File) { known[file.Id] = file.Hash })
+		if files := mock_fs.Files(fs); !equalFiles(known, files) {
+			t.Errorf("Controller sees\n%v\nbut the archives have\n%v", known, files)
+		}
+	})
+}
+
+func TestFailedCopiesAreRemoved(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{
+		"sizes": {"big": 120000},
+		"roots": [
+			{"root": "origin", "files": {"a/big.txt": "big", "locked.txt": "locked"}},
+			{"root": "copy", "files": {}},
+			{"root": "broken", "files": {}}
+		],
+		"faults": [
+			{"op": "copy", "root": "broken", "kind": "io", "at": 60000},
+			{"op": "copy", "root": "origin", "path": "locked.txt", "kind": "permission"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.do(func() {
+		known := map[m.Id]m.Hash{}
+		h.c.every(func(file *m.File) { known[file.Id] = file.Hash })
+		if files := mock_fs.Files(fs); !equalFiles(known, files) {
+			t.Errorf("Controller sees\n%v\nbut the archives have\n%v", known, files)
+		}
+		for _, name := range []string{"broken/a/big.txt", "origin/locked.txt"} {
+			if !h.c.failed[testId(name)] {
+				t.Errorf("Expected %s to have failed", name)
+			}
+		}
+	})
+}
+
+func TestSelectCopyOfOriginContent(t *testing.T) {
+	h := newHarness(t)
+	copy := testId("copy 2/xxx.txt")
+	h.send(m.ToggleDetails{}, m.MouseTarget{Command: m.SelectCopy(copy)})
+	h.do(func() {
+		if selected := h.c.selectedEntry(); selected == nil || selected.Id != copy {
+			t.Errorf("Expected %s to be selected, got %v", copy, selected)
+		}
+		for _, info := range h.c.view.Details.Copies {
+			if info.Id == copy && info.State != w.CopySelected {
+				t.Errorf("Expected the details to show %s as selected, got %s", copy, info.State)
+			}
+		}
+	})
+	h.send(m.MoveSelection{Lines: 1})
+	h.do(func() {
+		if h.c.entry(copy) != nil {
+			t.Errorf("Expected %s to be listed only while selected", copy)
+		}
+	})
+}
+
+// testId splits "root/path/base" into an id.
+func testId(name string) m.Id {
+	root, rest, _ := strings.Cut(name, "/")
+	path, base := "", rest
+	if idx := strings.LastIndex(rest, "/"); idx >= 0 {
+		path, base = rest[:idx], rest[idx+1:]
+	}
+	return m.Id{Root: m.Root(root), Name: m.Name{Path: m.Path(path), Base: m.Base(base)}}
+}
+
+func TestQueueDepthOnStatusLine(t *testing.T) {
+	events := stream.NewBounded[m.Event]("test", 4096, stream.Block)
+	h := newFsHarness(t, mock_fs.NewFs(events), events, []m.Root{"origin", "copy 1", "copy 2"}, &config.Config{})
+	h.send(m.Tick(time.Now()))
+	if screen := text.Render(h.renderer.Screen()); !strings.Contains(screen, "Queue: 0/4096, max ") {
+		t.Errorf("Expected the queue depth on the screen:\n%s", screen)
+	}
+}
+
+func TestDeletedAbsentFileIsNotCounted(t *testing.T) {
+	scenario, err := mock_fs.ParseScenario([]byte(`{"roots": [
+		{"root": "origin", "files": {"o.txt": "o"}},
+		{"root": "copy", "files": {"o.txt": "o", "n.txt": "new"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	fs := mock_fs.NewScenarioFs(events, scenario)
+	h := newFsHarness(t, fs, events, scenario.RootNames(), &config.Config{})
+	h.send(m.MouseTarget{Command: m.SelectFile(testId("copy/n.txt"))}, m.Delete{}, m.DialogSelect{})
+	h.settle()
+	h.do(func() {
+		if _, ok := mock_fs.Files(fs)[testId("copy/n.txt")]; ok {
+			t.Fatal("Expected copy/n.txt to be deleted")
+		}
+		if h.c.view.AbsentFiles != 0 || h.c.view.PendingFiles != 0 {
+			t.Errorf("Expected no absent or pending files, got %d absent, %d pending", h.c.view.AbsentFiles, h.c.view.PendingFiles)
+		}
+	})
+}
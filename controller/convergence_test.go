@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"arch/config"
+	"arch/files/mock_fs"
+	m "arch/model"
+	"arch/stream"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var convergenceRuns = flag.Int("runs", 200, "random archives checked by the convergence test")
+
+var (
+	testRoots   = []m.Root{"origin", "copy 1", "copy 2"}
+	testFolders = []string{"", "a/", "a/b/", "c/"}
+	testBases   = []string{"f.txt", "g.txt", "h", "f [1].txt"}
+)
+
+// randomScenario makes roots sharing few names and contents, so that name collisions,
+// moved and renamed files and identical contents under different names are common.
+func randomScenario(seed int64) *mock_fs.Scenario {
+	rng := rand.New(rand.NewSource(seed))
+	randomName := func() string {
+		return testFolders[rng.Intn(len(testFolders))] + testBases[rng.Intn(len(testBases))]
+	}
+	randomLabel := func() string {
+		return fmt.Sprintf("c%d", rng.Intn(8))
+	}
+	scenario := &mock_fs.Scenario{Seed: seed, Sizes: map[string]uint64{}}
+	for i := 0; i < 8; i++ {
+		scenario.Sizes[fmt.Sprintf("c%d", i)] = 1000
+	}
+
+	origin := map[string]string{}
+	for i := rng.Intn(7); i > 0; i-- {
+		origin[randomName()] = randomLabel()
+	}
+	scenario.Roots = append(scenario.Roots, mock_fs.RootScenario{Root: testRoots[0], Files: origin})
+	for _, root := range testRoots[1:] {
+		files := map[string]string{}
+		for name, label := range origin {
+			switch rng.Intn(10) {
+			case 0, 1, 2, 3:
+				files[name] = label
+			case 4:
+				files[testFolders[rng.Intn(len(testFolders))]+name[strings.LastIndex(name, "/")+1:]] = label
+			case 5:
+				files[name[:strings.LastIndex(name, "/")+1]+testBases[rng.Intn(len(testBases))]] = label
+			case 6:
+				files[name] = randomLabel()
+			}
+		}
+		for i := rng.Intn(3); i > 0; i-- {
+			files[randomName()] = randomLabel()
+		}
+		scenario.Roots = append(scenario.Roots, mock_fs.RootScenario{Root: root, Files: files})
+	}
+	return scenario
+}
+
+// TestConvergence runs autoresolve on random archives and checks that it reaches
+// a state where every root has the origin files and nothing got lost. Then it keeps
+// random files and folders and checks the same.
+func TestConvergence(t *testing.T) {
+	for seed := int64(0); seed < int64(*convergenceRuns); seed++ {
+		scenario := randomScenario(seed)
+		events := stream.NewStream[m.Event]("test")
+		fs := mock_fs.NewScenarioFs(events, scenario)
+		before := mock_fs.Files(fs)
+
+		after, deleted := resolve(t, fs, events, nil)
+		checkConvergence(t, seed, before, after, deleted)
+
+		rng := rand.New(rand.NewSource(seed))
+		kept, deleted := resolve(t, fs, events, func(h *harness) { keepRandomly(h, rng) })
+		checkKept(t, seed, after, kept, deleted)
+
+		if again, _ := resolve(t, fs, events, nil); !equalFiles(again, kept) {
+			t.Errorf("seed %d: running again changed the archives\nfrom %v\nto   %v", seed, kept, again)
+		}
+		if t.Failed() {
+			t.Fatalf("seed %d: scenario %+v", seed, scenario.Roots)
+		}
+	}
+}
+
+// resolve scans the roots, waits for autoresolve to finish, runs the actions and
+// returns the files on disk and the files the controller deleted.
+func resolve(t *testing.T, fs m.FS, events *stream.Stream[m.Event], actions func(h *harness)) (map[m.Id]m.Hash, []m.Id) {
+	recorder := &deleteRecorder{FS: fs}
+	h := newFsHarness(t, recorder, events, testRoots, &config.Config{})
+	if actions != nil {
+		actions(h)
+	}
+	known := map[m.Id]m.Hash{}
+	h.do(func() {
+		for _, err := range h.c.Errors {
+			t.Errorf("unexpected error: %v", err)
+		}
+		h.c.every(func(file *m.File) { known[file.Id] = file.Hash })
+	})
+	h.quit()
+	files := mock_fs.Files(fs)
+	if !equalFiles(known, files) {
+		t.Errorf("controller sees\n%v\nbut the archives have\n%v", known, files)
+	}
+	return files, recorder.deleted
+}
+
+// keepRandomly keeps files, keeps all in folders and syncs folders like a user would.
+func keepRandomly(h *harness, rng *rand.Rand) {
+	for i := rng.Intn(4); i > 0; i-- {
+		path := m.Path(strings.TrimSuffix(testFolders[rng.Intn(len(testFolders))], "/"))
+		switch rng.Intn(3) {
+		case 0:
+			h.do(func() { h.c.keepFile(randomKeepable(h.c, rng)) })
+			h.settle()
+		case 1:
+			h.send(m.MouseTarget{Command: m.SelectFolder(path)}, m.KeepAll{}, m.DialogSelect{})
+		case 2:
+			h.do(func() { h.c.keepFolder(path) })
+			h.send(m.DialogSelect{})
+		}
+	}
+}
+
+// randomKeepable picks any file of any root.
+func randomKeepable(c *controller, rng *rand.Rand) *m.File {
+	files := []*m.File{}
+	c.every(func(file *m.File) { files = append(files, file) })
+	if len(files) == 0 {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Id.String() < files[j].Id.String() })
+	return files[rng.Intn(len(files))]
+}
+
+// deleteRecorder records the files the controller deletes.
+type deleteRecorder struct {
+	m.FS
+	lock    sync.Mutex
+	deleted []m.Id
+}
+
+type recordingScanner struct {
+	m.ArchiveScanner
+	recorder *deleteRecorder
+}
+
+func (fs *deleteRecorder) NewArchiveScanner(root m.Root) m.ArchiveScanner {
+	return recordingScanner{ArchiveScanner: fs.FS.NewArchiveScanner(root), recorder: fs}
+}
+
+func (s recordingScanner) Send(cmd m.FileCommand) {
+	s.recorder.record(cmd)
+	s.ArchiveScanner.Send(cmd)
+}
+
+func (fs *deleteRecorder) record(cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.Batch:
+		for _, cmd := range cmd {
+			fs.record(cmd)
+		}
+	case m.DeleteFile:
+		fs.lock.Lock()
+		fs.deleted = append(fs.deleted, cmd.Id)
+		fs.lock.Unlock()
+	}
+}
+
+func checkConvergence(t *testing.T, seed int64, before, after map[m.Id]m.Hash, deleted []m.Id) {
+	t.Helper()
+	for _, id := range deleted {
+		if id.Root == testRoots[0] {
+			t.Errorf("seed %d: autoresolve deleted origin file %v", seed, id)
+		}
+	}
+	present := map[m.Hash]struct{}{}
+	for _, hash := range after {
+		present[hash] = struct{}{}
+	}
+	originCopies := map[m.Hash]int{}
+	for id, hash := range before {
+		if _, ok := present[hash]; !ok {
+			t.Errorf("seed %d: content of %v is lost", seed, id)
+		}
+		if id.Root == testRoots[0] {
+			originCopies[hash]++
+		}
+	}
+
+	for id, hash := range before {
+		if id.Root != testRoots[0] {
+			continue
+		}
+		if after[id] != hash {
+			t.Errorf("seed %d: origin file %v was changed or deleted", seed, id)
+		}
+		if originCopies[hash] > 1 {
+			continue
+		}
+		for _, root := range testRoots {
+			names := []m.Name{}
+			for other, otherHash := range after {
+				if other.Root == root && otherHash == hash {
+					names = append(names, other.Name)
+				}
+			}
+			if len(names) != 1 || names[0] != id.Name {
+				t.Errorf("seed %d: %v has copies %v in %q", seed, id, names, root)
+			}
+		}
+	}
+}
+
+func equalFiles(a, b map[m.Id]m.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, hash := range a {
+		if other, ok := b[id]; !ok || other != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// checkKept checks the archives after keeping. No content may get lost, origin files
+// may only be deleted as duplicates and must not come back, and content with one origin
+// file must be in every root.
+func checkKept(t *testing.T, seed int64, before, after map[m.Id]m.Hash, deleted []m.Id) {
+	t.Helper()
+	present := map[m.Hash]struct{}{}
+	originFiles := map[m.Hash]int{}
+	for id, hash := range after {
+		present[hash] = struct{}{}
+		if id.Root == testRoots[0] {
+			originFiles[hash]++
+		}
+	}
+	for id, hash := range before {
+		if _, ok := present[hash]; ok {
+			continue
+		}
+		t.Errorf("seed %d: content of %v is lost", seed, id)
+	}
+
+	for _, id := range deleted {
+		if id.Root != testRoots[0] {
+			continue
+		}
+		if _, ok := after[id]; ok {
+			t.Errorf("seed %d: origin file %v was deleted and created again", seed, id)
+		}
+		if originFiles[before[id]] == 0 {
+			t.Errorf("seed %d: origin file %v was deleted, but was not a duplicate", seed, id)
+		}
+	}
+
+	for id, hash := range after {
+		if id.Root != testRoots[0] || originFiles[hash] > 1 {
+			continue
+		}
+		for _, root := range testRoots {
+			names := []m.Name{}
+			for other, otherHash := range after {
+				if other.Root == root && otherHash == hash {
+					names = append(names, other.Name)
+				}
+			}
+			if len(names) != 1 || names[0] != id.Name {
+				t.Errorf("seed %d: %v has copies %v in %q", seed, id, names, root)
+			}
+		}
+	}
+}
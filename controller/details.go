@@ -0,0 +1,110 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"sort"
+	"strings"
+)
+
+func (c *controller) details() *w.Details {
+	selected := c.selectedEntry()
+	if !c.showDetails || selected == nil {
+		return nil
+	}
+	if selected.Id != c.detailsId {
+		c.detailsId, c.detailsOffset = selected.Id, 0
+	}
+	details := &w.Details{
+		Name:    selected.Name,
+		Kind:    selected.Kind,
+		Hash:    selected.Hash,
+		Size:    selected.Size,
+		ModTime: selected.ModTime,
+		Offset:  c.detailsOffset,
+	}
+	if selected.Kind == w.FileFolder {
+		c.folderDetails(details, m.Path(selected.Name.String()))
+		return details
+	}
+
+	files := c.files[selected.Hash]
+	perRoot := map[m.Root]int{}
+	for _, file := range files {
+		perRoot[file.Root]++
+	}
+	for _, file := range files {
+		state := w.CopyPresent
+		switch {
+		case file.Id == selected.Id:
+			state = w.CopySelected
+		case file.Name != selected.Name:
+			state = w.CopyMisnamed
+		}
+		if perRoot[file.Root] > 1 && state != w.CopySelected {
+			state = w.CopyDuplicate
+		}
+		details.Copies = append(details.Copies, w.CopyInfo{Id: file.Id, State: state})
+	}
+	for _, root := range c.roots {
+		if perRoot[root] == 0 {
+			details.Copies = append(details.Copies, w.CopyInfo{Id: m.Id{Root: root, Name: selected.Name}, State: w.CopyMissing})
+		}
+	}
+	sort.Slice(details.Copies, func(i, j int) bool {
+		return strings.ToLower(details.Copies[i].Id.String()) < strings.ToLower(details.Copies[j].Id.String())
+	})
+	return details
+}
+
+func (c *controller) folderDetails(details *w.Details, path m.Path) {
+	node := c.tree.find(path)
+	if node == nil {
+		return
+	}
+	initial := c.archives[c.origin].progressState == m.Initial
+	for b, folderTotal := range node.totalsOf() {
+		if !b.origin && initial {
+			continue
+		}
+		details.Files += folderTotal.count
+		details.States[b.state] += folderTotal.count
+	}
+}
+
+func (c *controller) scrollDetails(lines int) {
+	if c.view.Details == nil {
+		return
+	}
+	c.detailsOffset += lines
+	if last := len(c.view.Details.Copies) - 1; c.detailsOffset > last {
+		c.detailsOffset = last
+	}
+	if c.detailsOffset < 0 {
+		c.detailsOffset = 0
+	}
+}
+
+// selectCopy selects the copy in its folder. Copies which the folder does not list,
+// like copies of content the origin has, are pinned to the listing while selected.
+func (c *controller) selectCopy(id m.Id) {
+	c.search = nil
+	c.problems = false
+	c.currentPath = id.Path
+	c.pinnedCopy = id
+	c.currentFolder().selectedId = id
+}
+
+// addPinnedCopy lists the pinned copy in its folder as long as it stays selected.
+func (c *controller) addPinnedCopy() {
+	id := c.pinnedCopy
+	if id.Path != c.currentPath || c.currentFolder().selectedId != id || c.entry(id) != nil {
+		return
+	}
+	file := c.fileAt(id)
+	if file == nil {
+		return
+	}
+	b := bucket{state: c.state[file.Hash], origin: file.Root == c.origin, failed: c.failed[id]}
+	c.view.Entries = append(c.view.Entries, c.fileEntry(leaf{file: file, bucket: b}))
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	w "arch/widgets"
+)
+
+type dialog struct {
+	title   string
+	lines   []string
+	buttons []string
+	focused int
+	confirm func()
+}
+
+func (c *controller) showDialog(title string, lines []string, confirmLabel string, confirm func()) {
+	c.dialog = &dialog{
+		title:   " " + title + " ",
+		lines:   lines,
+		buttons: []string{confirmLabel, "Cancel"},
+		confirm: confirm,
+	}
+}
+
+func (c *controller) showMessage(title string, lines []string) {
+	c.dialog = &dialog{
+		title:   " " + title + " ",
+		lines:   lines,
+		buttons: []string{"OK"},
+		confirm: func() {},
+	}
+}
+
+func (c *controller) dialogFocus(delta int) {
+	if c.dialog == nil {
+		return
+	}
+	n := len(c.dialog.buttons)
+	c.dialog.focused = ((c.dialog.focused+delta)%n + n) % n
+}
+
+func (c *controller) dialogSelect(button int) {
+	d := c.dialog
+	if d == nil {
+		return
+	}
+	c.dialog = nil
+	if button == 0 {
+		d.confirm()
+	}
+	c.showOutput()
+}
+
+func (c *controller) cancel() {
+	if c.pane != nil {
+		c.pane = nil
+		return
+	}
+	c.dialog = nil
+	c.showOutput()
+}
+
+// showOutput shows the output of the last command once no dialog waits for an answer.
+func (c *controller) showOutput() {
+	if c.dialog != nil || c.output == nil {
+		return
+	}
+	c.pane, c.output = c.output, nil
+}
+
+func (d *dialog) info() *w.DialogInfo {
+	if d == nil {
+		return nil
+	}
+	return &w.DialogInfo{
+		Title:   d.title,
+		Lines:   d.lines,
+		Buttons: d.buttons,
+		Focused: d.focused,
+	}
+}
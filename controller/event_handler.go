@@ -2,6 +2,7 @@ package controller
 
 import (
 	m "arch/model"
+	w "arch/widgets"
 	"log"
 )
 
@@ -9,6 +10,7 @@ func (c *controller) handleEvent(event any) {
 	if event == nil {
 		return
 	}
+	c.markDirty(event)
 	switch event := event.(type) {
 	case m.TotalSize:
 		c.totalSize(event)
@@ -49,8 +51,11 @@ func (c *controller) handleEvent(event any) {
 	case m.Exit:
 		c.exit()
 
-	case m.RevealInFinder:
-		c.revealInFinder()
+	case m.Reveal:
+		c.reveal()
+
+	case m.OpenFailed:
+		c.showMessage("Cannot open", []string{event.Path, event.Err.Error()})
 
 	case m.MoveSelection:
 		c.moveSelection(event.Lines)
@@ -62,7 +67,13 @@ func (c *controller) handleEvent(event any) {
 		c.selectLast()
 
 	case m.Scroll:
-		c.shiftOffset(event.Lines)
+		if c.pane != nil {
+			c.scrollPane(event.Lines)
+		} else if event.Command == (m.ScrollDetails{}) {
+			c.scrollDetails(event.Lines)
+		} else {
+			c.shiftOffset(event.Lines)
+		}
 
 	case m.MouseTarget:
 		c.mouseTarget(event.Command)
@@ -80,20 +91,95 @@ func (c *controller) handleEvent(event any) {
 		c.keepSelected()
 
 	case m.KeepAll:
-		// TODO: Implement, maybe?
+		c.keepAll()
+
+	case m.Cancel:
+		c.cancel()
+
+	case m.DialogFocus:
+		c.dialogFocus(event.Delta)
+
+	case m.DialogSelect:
+		if c.pane != nil {
+			c.pane = nil
+		} else if c.dialog != nil {
+			c.dialogSelect(c.dialog.focused)
+		}
+
+	case m.DialogScroll:
+		if c.pane != nil {
+			c.scrollPane(event.Lines)
+		}
+
+	case m.ToggleHelp:
+		c.toggleHelp()
+
+	case m.RunCommand:
+		c.runCommand(event.Name)
+
+	case m.CommandOutput:
+		c.output = &w.PaneInfo{Title: event.Title, Rows: event.Lines}
+		c.showOutput()
+
+	case m.ToggleDetails:
+		c.showDetails = !c.showDetails
+
+	case m.TogglePresence:
+		c.showPresence = !c.showPresence
+		if !c.showPresence {
+			c.tree.dropPresence()
+		}
+
+	case m.SortNext:
+		c.sortNext()
+
+	case m.SortReverse:
+		c.sortReverse()
+
+	case m.Search:
+		c.startSearch(event.Recursive)
+
+	case m.TextInput, m.TextBackspace, m.TextTab, m.TextEnter, m.TextCancel:
+		c.handleTextInput(event)
 
 	case m.Delete:
-		c.deleteFile(c.selectedEntry())
+		c.deleteSelected()
+
+	case m.ToggleMark:
+		c.toggleMark()
+
+	case m.ExtendMark:
+		c.extendMark(event.Lines)
+
+	case m.MarkByState:
+		c.markByState()
+
+	case m.ClearMarks:
+		c.clearMarks()
+
+	case m.MouseDrag:
+		c.mouseDrag(event.Command)
 
 	case m.Error:
 		log.Printf("### Error: %s", event)
 		c.Errors = append(c.Errors, event)
+		c.failed[event.Id] = true
+		c.touchName(event.Id.Name)
+
+	case m.ToggleProblems:
+		c.problems = !c.problems
+		c.search = nil
+
+	case m.CycleFilter:
+		c.filter = (c.filter + 1) % (w.FilterFailed + 1)
+		c.currentFolder().offsetIdx = 0
 
 	case m.Quit:
 		c.quit = true
 
 	case m.Debug:
 		log.Println(c.view.String())
+		log.Printf("### events: %+v", c.events.Stats())
 
 	default:
 		log.Panicf("### unhandled event: %#v", event)
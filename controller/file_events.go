@@ -12,6 +12,8 @@ func (c *controller) totalSize(event m.TotalSize) {
 
 func (c *controller) fileScanned(event m.FileScanned) {
 	c.files[event.Hash] = append(c.files[event.Hash], event.File)
+	c.byId = nil
+	c.touch(event.Hash)
 	archive := c.archives[event.Root]
 	archive.totalHashed += event.File.Size
 	archive.fileHashed = 0
@@ -39,17 +41,17 @@ func (c *controller) handleCopyingProgress(event m.CopyingProgress) {
 
 func (c *controller) fileDeleted(event m.FileDeleted) {
 	log.Printf("### %s", event)
-	c.state[event.Hash] = w.Resolved
+	c.commandDone(event.Hash, m.DeleteFile(event))
 }
 
 func (c *controller) fileRenamed(event m.FileRenamed) {
 	log.Printf("### %s", event)
-	c.state[event.Hash] = w.Resolved
+	c.commandDone(event.Hash, m.RenameFile(event))
 }
 
 func (c *controller) fileCopied(event m.FileCopied) {
 	log.Printf("### %s", event)
-	c.state[event.Hash] = w.Resolved
+	c.commandDone(event.Hash, m.CopyFile(event))
 	c.fileCopiedSize = 0
 	file := c.files[event.Hash][0]
 	c.totalCopiedSize += file.Size
@@ -57,3 +59,35 @@ func (c *controller) fileCopied(event m.FileCopied) {
 		c.totalCopiedSize, c.copySize = 0, 0
 	}
 }
+
+// commandDone resolves the hash once all the commands sent for it are done. A failed rename or delete
+// is taken back, so that the failure shows on the file as it still is. The targets of
+// a failed copy are removed, and so are all of them when reading the source failed.
+func (c *controller) commandDone(hash m.Hash, cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.CopyFile:
+		for _, id := range cmd.To {
+			if c.failed[cmd.From] || c.failed[id] {
+				c.touch(hash)
+				c.removeFile(hash, id)
+			}
+		}
+	case m.RenameFile:
+		if file := c.file(hash, cmd.To); file != nil && c.failed[cmd.From] {
+			c.touch(hash)
+			c.moveFile(file, cmd.From)
+		}
+	case m.DeleteFile:
+		if file, ok := c.deleted[cmd.Id]; ok && c.failed[cmd.Id] {
+			c.touch(hash)
+			c.addFile(file)
+		}
+		delete(c.deleted, cmd.Id)
+	}
+	c.inFlight[hash]--
+	if c.inFlight[hash] > 0 {
+		return
+	}
+	delete(c.inFlight, hash)
+	c.setState(hash, w.Resolved)
+}
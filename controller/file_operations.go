@@ -3,6 +3,7 @@ package controller
 import (
 	m "arch/model"
 	w "arch/widgets"
+	"fmt"
 	"strings"
 )
 
@@ -11,11 +12,59 @@ func (c *controller) keepFile(file *m.File) {
 		return
 	}
 
-	scanner := c.archives[c.origin].scanner
-	files := c.files[file.Hash]
-	pending := false
+	cmds := c.keepCommands(c, file)
+	for _, cmd := range cmds {
+		c.apply(cmd)
+	}
+	if len(cmds) > 0 {
+		c.setState(file.Hash, w.Pending)
+	}
+}
+
+// fileIndex finds files in the model, or in the model as planned commands leave it.
+type fileIndex interface {
+	filesOf(hash m.Hash) []*m.File
+	fileAt(id m.Id) *m.File
+}
 
+// keepCommands returns the commands which keep the file, and make the roots agree on it.
+func (c *controller) keepCommands(idx fileIndex, file *m.File) []m.FileCommand {
+	files := idx.filesOf(file.Hash)
+	cmds := []m.FileCommand{}
+
+	// Origin names win, like in autoresolve: other contents under the name are renamed
+	// away, unless the origin has one, then the kept content gets a free name. Names are
+	// reserved as they are chosen, since the renames are not applied yet. Copies of the
+	// same content get the same name, like uniqueName does for autoresolve.
+	reserved := map[m.Name]struct{}{}
+	taken := func(name m.Name) bool {
+		_, ok := reserved[name]
+		return ok || c.nameTaken(idx, name)
+	}
 	fileName := file.Name
+	if other := idx.fileAt(m.Id{Root: c.origin, Name: fileName}); other != nil && other.Hash != file.Hash {
+		fileName = freeName(fileName, taken)
+		reserved[fileName] = struct{}{}
+	}
+	renamings := map[m.Hash]m.Name{}
+	for _, root := range c.roots {
+		other := idx.fileAt(m.Id{Root: root, Name: fileName})
+		if other == nil || other.Hash == file.Hash {
+			continue
+		}
+		newName, ok := renamings[other.Hash]
+		if !ok {
+			newName = freeName(fileName, taken)
+			reserved[newName] = struct{}{}
+			renamings[other.Hash] = newName
+		}
+		cmds = append(cmds, m.RenameFile{From: other.Id, To: m.Id{Root: root, Name: newName}, Hash: other.Hash})
+	}
+	fileId := m.Id{Root: file.Root, Name: fileName}
+	if fileId != file.Id {
+		cmds = append(cmds, m.RenameFile{From: file.Id, To: fileId, Hash: file.Hash})
+	}
+
 	keepFiles := map[m.Root]*m.File{}
 	for _, entry := range files {
 		root := entry.Root
@@ -32,100 +81,235 @@ func (c *controller) keepFile(file *m.File) {
 			keepFiles[root] = entry
 		}
 	}
+	keepFiles[file.Root] = file
 
 	for _, entry := range files {
 		if entry.Id == file.Id {
 			continue
 		}
-		root := entry.Root
-		keepFile := keepFiles[root]
+		keepFile := keepFiles[entry.Root]
 		if entry == keepFile {
 			if fileName != keepFile.Name {
 				newId := m.Id{Root: keepFile.Root, Name: fileName}
-				scanner.Send(m.RenameFile{From: keepFile.Id, To: newId, Hash: file.Hash})
-				keepFile.Id = newId
-				pending = true
+				cmds = append(cmds, m.RenameFile{From: keepFile.Id, To: newId, Hash: file.Hash})
 			}
 		} else {
-			scanner.Send(m.DeleteFile{Id: entry.Id, Hash: file.Hash})
-			pending = true
-			for i, file := range files {
-				if file.Id == entry.Id {
-					files[i] = files[len(files)-1]
-					c.files[file.Hash] = files[:len(files)-1]
-					break
-				}
-			}
+			cmds = append(cmds, m.DeleteFile{Id: entry.Id, Hash: file.Hash})
 		}
 	}
 
-	copy := m.CopyFile{From: file.Id, Hash: file.Hash}
+	copy := m.CopyFile{From: fileId, Hash: file.Hash}
 	for _, root := range c.roots {
 		if root == file.Root {
 			continue
 		}
 		if _, ok := keepFiles[root]; !ok {
-			newId := m.Id{Root: root, Name: fileName}
-			copy.To = append(copy.To, newId)
-			newFile := &m.File{
-				Id:      newId,
-				Size:    file.Size,
-				ModTime: file.ModTime,
-				Hash:    file.Hash,
-			}
-			c.files[file.Hash] = append(c.files[file.Hash], newFile)
+			copy.To = append(copy.To, m.Id{Root: root, Name: fileName})
 		}
 	}
 	if len(copy.To) > 0 {
-		scanner.Send(copy)
-		pending = true
-		c.copySize += file.Size
+		cmds = append(cmds, copy)
 	}
-	if pending {
-		c.state[file.Hash] = w.Pending
+	return cmds
+}
+
+func (c *controller) apply(cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.RenameFile:
+		c.touch(cmd.Hash)
+		if file := c.file(cmd.Hash, cmd.From); file != nil {
+			c.moveFile(file, cmd.To)
+		}
+
+	case m.DeleteFile:
+		c.touch(cmd.Hash)
+		if file := c.removeFile(cmd.Hash, cmd.Id); file != nil {
+			c.deleted[cmd.Id] = file
+		}
+
+	case m.CopyFile:
+		c.touch(cmd.Hash)
+		source := c.file(cmd.Hash, cmd.From)
+		if source == nil {
+			return
+		}
+		for _, id := range cmd.To {
+			c.addFile(&m.File{
+				Id:      id,
+				Size:    source.Size,
+				ModTime: source.ModTime,
+				Hash:    source.Hash,
+			})
+		}
+		c.copySize += source.Size
 	}
+	c.send(cmd)
 }
 
-func (c *controller) deleteFile(file *w.File) {
-	if file.Kind == w.FileFolder {
-		c.deleteFolderFile(file)
-	} else {
-		c.deleteRegularFile(file.Hash)
+func (c *controller) file(hash m.Hash, id m.Id) *m.File {
+	for _, file := range c.files[hash] {
+		if file.Id == id {
+			return file
+		}
 	}
+	return nil
+}
 
-	c.state[file.Hash] = w.Pending
+func (c *controller) filesOf(hash m.Hash) []*m.File {
+	return c.files[hash]
 }
 
-func (c *controller) deleteRegularFile(hash m.Hash) {
-	if c.state[hash] != w.Absent {
-		return
+// fileAt returns the file having the id. The index is built when first needed
+// after scanning added files, and kept current by apply.
+func (c *controller) fileAt(id m.Id) *m.File {
+	if c.byId == nil {
+		c.byId = map[m.Id]*m.File{}
+		c.every(func(file *m.File) { c.byId[file.Id] = file })
 	}
-	c.state[hash] = w.Pending
-	c.every(func(entry *m.File) {
-		if entry.Hash == hash {
-			c.archives[c.origin].scanner.Send(m.DeleteFile{Id: entry.Id, Hash: entry.Hash})
-			files := c.files[hash]
-			for i, file := range files {
-				if file.Id == entry.Id {
-					files[i] = files[len(files)-1]
-					c.files[file.Hash] = files[:len(files)-1]
-					break
-				}
+	return c.byId[id]
+}
+
+// nameTaken tells if any root has a file with the name.
+func (c *controller) nameTaken(idx fileIndex, name m.Name) bool {
+	for _, root := range c.roots {
+		if idx.fileAt(m.Id{Root: root, Name: name}) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *controller) addFile(file *m.File) {
+	c.files[file.Hash] = append(c.files[file.Hash], file)
+	if c.byId != nil {
+		c.byId[file.Id] = file
+	}
+}
+
+func (c *controller) moveFile(file *m.File, id m.Id) {
+	if c.byId != nil {
+		delete(c.byId, file.Id)
+		c.byId[id] = file
+	}
+	file.Id = id
+}
+
+func (c *controller) removeFile(hash m.Hash, id m.Id) *m.File {
+	files := c.files[hash]
+	for i, file := range files {
+		if file.Id == id {
+			files[i] = files[len(files)-1]
+			c.files[hash] = files[:len(files)-1]
+			if c.byId != nil {
+				delete(c.byId, id)
 			}
+			return file
 		}
-	})
+	}
+	return nil
 }
 
-func (c *controller) deleteFolderFile(file *w.File) {
-	path := file.Name.String()
+func (c *controller) deleteHashes(entries []*w.File) map[m.Hash]struct{} {
 	hashes := map[m.Hash]struct{}{}
-	c.every(func(entry *m.File) {
-		if c.state[entry.Hash] == w.Absent && strings.HasPrefix(entry.Path.String(), path) {
+	for _, entry := range entries {
+		if entry.Kind == w.FileFolder {
+			path := m.Path(entry.Name.String())
+			c.every(func(file *m.File) {
+				if c.state[file.Hash] == w.Absent && inFolder(file.Path, path) {
+					hashes[file.Hash] = struct{}{}
+				}
+			})
+		} else if c.state[entry.Hash] == w.Absent {
 			hashes[entry.Hash] = struct{}{}
 		}
-	})
+	}
+	return hashes
+}
 
+func (c *controller) deleteEntries(entries []*w.File) {
+	hashes := c.deleteHashes(entries)
+	if len(hashes) == 0 {
+		return
+	}
+	files, bytes := 0, uint64(0)
+	roots := map[m.Root]struct{}{}
 	for hash := range hashes {
-		c.deleteRegularFile(hash)
+		for _, file := range c.files[hash] {
+			files++
+			bytes += file.Size
+			roots[file.Root] = struct{}{}
+		}
+	}
+	lines := []string{
+		fmt.Sprintf("Delete %d files (%s bytes) in %d roots?", files, strings.TrimSpace(w.FormatSize(bytes)), len(roots)),
+		"Deleted files cannot be restored.",
+	}
+	c.showDialog("Delete", lines, "Delete", func() {
+		c.sendBatch(func() {
+			for hash := range hashes {
+				c.deleteRegularFile(hash)
+			}
+		})
+		c.clearMarks()
+	})
+}
+
+func (c *controller) deleteRegularFile(hash m.Hash) {
+	if c.state[hash] != w.Absent {
+		return
+	}
+	c.setState(hash, w.Pending)
+	files := append([]*m.File{}, c.files[hash]...)
+	for _, file := range files {
+		c.apply(m.DeleteFile{Id: file.Id, Hash: hash})
+	}
+}
+
+func (c *controller) send(cmd m.FileCommand) {
+	for _, id := range commandIds(cmd) {
+		if _, ok := c.failed[id]; ok {
+			delete(c.failed, id)
+			c.touchName(id.Name)
+		}
+	}
+	c.inFlight[commandHash(cmd)]++
+	if c.batching {
+		c.batch = append(c.batch, cmd)
+		return
+	}
+	c.archives[c.origin].scanner.Send(cmd)
+}
+
+func commandIds(cmd m.FileCommand) []m.Id {
+	switch cmd := cmd.(type) {
+	case m.RenameFile:
+		return []m.Id{cmd.From, cmd.To}
+	case m.DeleteFile:
+		return []m.Id{cmd.Id}
+	case m.CopyFile:
+		return append([]m.Id{cmd.From}, cmd.To...)
+	}
+	return nil
+}
+
+func commandHash(cmd m.FileCommand) m.Hash {
+	switch cmd := cmd.(type) {
+	case m.RenameFile:
+		return cmd.Hash
+	case m.DeleteFile:
+		return cmd.Hash
+	case m.CopyFile:
+		return cmd.Hash
+	}
+	return m.Hash{}
+}
+
+func (c *controller) sendBatch(f func()) {
+	c.batching = true
+	f()
+	c.batching = false
+	if len(c.batch) > 0 {
+		c.archives[c.origin].scanner.Send(m.Batch(c.batch))
 	}
+	c.batch = nil
 }
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"strings"
+	"time"
+)
+
+// folderTree indexes the listed files by folder. Every folder keeps totals of the files
+// below it, grouped into buckets, so listing a folder costs time proportional to its own
+// entries rather than to the whole archive. Folders also sum up how the files below
+// them are present in each root, which presence tells for a single file. These sums
+// are only counted when asked for, since they are shown only for the listed folders
+// and only while the presence columns are on.
+type folderTree struct {
+	folders  map[m.Path]*folderNode
+	presence func(file *m.File) []w.Presence
+}
+
+type folderNode struct {
+	tree     *folderTree
+	path     m.Path
+	parent   *folderNode
+	folders  map[m.Base]*folderNode
+	files    map[leafKey]leaf
+	totals   map[bucket]total
+	presence map[bucket][]w.Presence // nil until counted
+	stale    bool
+}
+
+// leafKey identifies a listed file within its folder. Copies of absent content
+// having the same name in several roots are listed once.
+type leafKey struct {
+	base m.Base
+	hash m.Hash
+}
+
+type leaf struct {
+	file *m.File
+	bucket
+}
+
+// bucket groups the files which filters show or hide together.
+type bucket struct {
+	state  w.State
+	origin bool
+	failed bool
+}
+
+type total struct {
+	count   int
+	size    uint64
+	modTime time.Time
+}
+
+func (t total) add(other total) total {
+	t.count += other.count
+	t.size += other.size
+	if t.modTime.Before(other.modTime) {
+		t.modTime = other.modTime
+	}
+	return t
+}
+
+func fileTotal(file *m.File) total {
+	return total{count: 1, size: file.Size, modTime: file.ModTime}
+}
+
+// sumPresence adds the counts to the sum, allocating it when empty.
+func sumPresence(sum, presence []w.Presence) []w.Presence {
+	if len(presence) == 0 {
+		return sum
+	}
+	if sum == nil {
+		sum = make([]w.Presence, len(presence))
+	}
+	for i, p := range presence {
+		sum[i].Present += p.Present
+		sum[i].Missing += p.Missing
+		sum[i].Misnamed += p.Misnamed
+		sum[i].Duplicated += p.Duplicated
+	}
+	return sum
+}
+
+func newFolderTree() *folderTree {
+	t := &folderTree{folders: map[m.Path]*folderNode{}}
+	t.folders[""] = newFolderNode(t, "", nil)
+	return t
+}
+
+func newFolderNode(tree *folderTree, path m.Path, parent *folderNode) *folderNode {
+	return &folderNode{
+		tree:    tree,
+		path:    path,
+		parent:  parent,
+		folders: map[m.Base]*folderNode{},
+		files:   map[leafKey]leaf{},
+		totals:  map[bucket]total{},
+	}
+}
+
+func (t *folderTree) find(path m.Path) *folderNode {
+	return t.folders[path]
+}
+
+func (t *folderTree) folder(path m.Path) *folderNode {
+	if node, ok := t.folders[path]; ok {
+		return node
+	}
+	parentPath, name := m.Path(""), m.Base(path)
+	if idx := strings.LastIndex(path.String(), "/"); idx >= 0 {
+		parentPath, name = path[:idx], m.Base(path[idx+1:])
+	}
+	parent := t.folder(parentPath)
+	node := newFolderNode(t, path, parent)
+	parent.folders[name] = node
+	t.folders[path] = node
+	return node
+}
+
+// add lists the file, unless a file with the same name and hash is already listed.
+func (t *folderTree) add(file *m.File, b bucket) {
+	node := t.folder(file.Path)
+	key := leafKey{base: file.Base, hash: file.Hash}
+	if _, ok := node.files[key]; ok {
+		return
+	}
+	node.files[key] = leaf{file: file, bucket: b}
+	for n := node; n != nil && !n.stale; n = n.parent {
+		n.totals[b] = n.totals[b].add(fileTotal(file))
+	}
+	node.dropPresence()
+}
+
+func (t *folderTree) presenceOf(file *m.File) []w.Presence {
+	if t.presence == nil {
+		return nil
+	}
+	return t.presence(file)
+}
+
+func (t *folderTree) remove(file *m.File) {
+	node, ok := t.folders[file.Path]
+	if !ok {
+		return
+	}
+	key := leafKey{base: file.Base, hash: file.Hash}
+	if _, ok := node.files[key]; ok {
+		delete(node.files, key)
+		node.invalidate()
+		node.dropPresence()
+	}
+}
+
+// invalidate marks the totals of the node and its parents to be recounted when needed.
+// The latest modification time cannot be taken back out of a total.
+func (n *folderNode) invalidate() {
+	for ; n != nil && !n.stale; n = n.parent {
+		n.stale = true
+	}
+}
+
+func (n *folderNode) totalsOf() map[bucket]total {
+	if !n.stale {
+		return n.totals
+	}
+	n.totals = map[bucket]total{}
+	for _, l := range n.files {
+		n.totals[l.bucket] = n.totals[l.bucket].add(fileTotal(l.file))
+	}
+	for _, child := range n.folders {
+		for b, childTotal := range child.totalsOf() {
+			n.totals[b] = n.totals[b].add(childTotal)
+		}
+	}
+	n.stale = false
+	return n.totals
+}
+
+// dropPresence frees the presence sums counted for all folders.
+func (t *folderTree) dropPresence() {
+	for _, node := range t.folders {
+		node.presence = nil
+	}
+}
+
+// dropPresence makes the node and its parents count their presence sums again when asked.
+func (n *folderNode) dropPresence() {
+	for ; n != nil; n = n.parent {
+		n.presence = nil
+	}
+}
+
+// presenceOf returns the presence sums of the files below the node by bucket.
+func (n *folderNode) presenceOf() map[bucket][]w.Presence {
+	if n.presence != nil {
+		return n.presence
+	}
+	n.presence = map[bucket][]w.Presence{}
+	for _, l := range n.files {
+		n.presence[l.bucket] = sumPresence(n.presence[l.bucket], n.tree.presenceOf(l.file))
+	}
+	for _, child := range n.folders {
+		for b, presence := range child.presenceOf() {
+			n.presence[b] = sumPresence(n.presence[b], presence)
+		}
+	}
+	return n.presence
+}
+
+// touch takes the files of the hash out of the tree, to be listed again by updateTree.
+// It has to be called before a file of the hash is renamed or removed.
+func (c *controller) touch(hash m.Hash) {
+	if _, ok := c.touched[hash]; ok {
+		return
+	}
+	c.touched[hash] = struct{}{}
+	for _, file := range c.files[hash] {
+		c.tree.remove(file)
+	}
+}
+
+func (c *controller) setState(hash m.Hash, state w.State) {
+	c.storeState(hash, state)
+	c.touch(hash)
+}
+
+func (c *controller) storeState(hash m.Hash, state w.State) {
+	if old, ok := c.state[hash]; ok {
+		c.stateCounts[old]--
+	}
+	c.state[hash] = state
+	c.stateCounts[state]++
+}
+
+// forget drops the hash once its last file is gone, so that it is not counted anymore.
+func (c *controller) forget(hash m.Hash) {
+	delete(c.files, hash)
+	if state, ok := c.state[hash]; ok {
+		c.stateCounts[state]--
+		delete(c.state, hash)
+	}
+}
+
+// touchName reindexes the listed files having the name, after their failed flag changed.
+func (c *controller) touchName(name m.Name) {
+	if node := c.tree.find(name.Path); node != nil {
+		for key := range node.files {
+			if key.base == name.Base {
+				c.touch(key.hash)
+			}
+		}
+	}
+}
+
+// updateTree recalculates the states of the touched hashes and reindexes their files.
+// Hashes without files are forgotten once no command for them is pending.
+// Copies outside of the origin are only listed while their content is absent from it.
+func (c *controller) updateTree() {
+	for hash := range c.touched {
+		files := c.files[hash]
+		if len(files) == 0 {
+			if c.state[hash] != w.Pending {
+				c.forget(hash)
+			}
+			continue
+		}
+		state := c.calcState(hash, files)
+		c.storeState(hash, state)
+		for _, file := range files {
+			origin := file.Root == c.origin
+			if !origin && state != w.Absent {
+				continue
+			}
+			failed := c.failed[file.Id]
+			c.tree.add(file, bucket{state: state, origin: origin, failed: failed})
+		}
+	}
+	c.touched = map[m.Hash]struct{}{}
+}
+
+// listed tells if files of the bucket are shown with the current filter.
+func (c *controller) listed(b bucket) bool {
+	if !b.origin && c.archives[c.origin].progressState == m.Initial {
+		return false
+	}
+	return c.passesFilter(b.state, b.failed)
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"testing"
+	"time"
+)
+
+func TestFolderTreeTotals(t *testing.T) {
+	tree := newFolderTree()
+	file := func(path, base string, hash byte, size uint64, day int) *m.File {
+		return &m.File{
+			Id:      m.Id{Root: "origin", Name: m.Name{Path: m.Path(path), Base: m.Base(base)}},
+			Size:    size,
+			ModTime: time.Date(2020, 1, day, 0, 0, 0, 0, time.UTC),
+			Hash:    m.Hash{hash},
+		}
+	}
+	resolved := bucket{state: w.Resolved, origin: true}
+	absent := bucket{state: w.Absent}
+	removed := file("a/b/c", "y", 2, 20, 3)
+	tree.add(file("a/b", "x", 1, 10, 1), resolved)
+	tree.add(removed, resolved)
+	tree.add(file("a", "z", 3, 30, 2), absent)
+	tree.add(file("a", "z", 3, 30, 2), absent)
+
+	totals := tree.find("a").totalsOf()
+	if got := totals[resolved]; got.count != 2 || got.size != 30 || got.modTime.Day() != 3 {
+		t.Errorf("Unexpected resolved total %+v", got)
+	}
+	if got := totals[absent]; got.count != 1 || got.size != 30 {
+		t.Errorf("Expected the duplicate name to be counted once, got %+v", got)
+	}
+
+	tree.remove(removed)
+	if tree.find("a").totalsOf()[resolved] != (total{count: 1, size: 10, modTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}) {
+		t.Errorf("Unexpected total after removal %+v", tree.find("a").totalsOf()[resolved])
+	}
+	if got := tree.find("").totalsOf()[resolved].count; got != 1 {
+		t.Errorf("Expected root to count 1 resolved file, got %d", got)
+	}
+}
+
+func TestFolderTreePresence(t *testing.T) {
+	tree := newFolderTree()
+	tree.presence = func(file *m.File) []w.Presence {
+		if file.Base == "x" {
+			return []w.Presence{{Present: 1}, {Missing: 1}}
+		}
+		return []w.Presence{{Present: 1}, {Misnamed: 1, Duplicated: 2}}
+	}
+	file := func(path, base string, hash byte) *m.File {
+		return &m.File{Id: m.Id{Root: "origin", Name: m.Name{Path: m.Path(path), Base: m.Base(base)}}, Hash: m.Hash{hash}}
+	}
+	resolved := bucket{state: w.Resolved, origin: true}
+	removed := file("a/b", "y", 2)
+	tree.add(file("a/b", "x", 1), resolved)
+	tree.add(removed, resolved)
+
+	want := []w.Presence{{Present: 2}, {Missing: 1, Misnamed: 1, Duplicated: 2}}
+	if got := tree.find("a").presenceOf()[resolved]; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected presence %v, got %v", want, got)
+	}
+	tree.remove(removed)
+	want = []w.Presence{{Present: 1}, {Missing: 1}}
+	if got := tree.find("").presenceOf()[resolved]; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected presence %v after removal, got %v", want, got)
+	}
+}
+
+func TestFolderTreeCountsPresenceWhenAsked(t *testing.T) {
+	tree := newFolderTree()
+	counted := 0
+	tree.presence = func(file *m.File) []w.Presence {
+		counted++
+		return []w.Presence{{Present: 1}}
+	}
+	resolved := bucket{state: w.Resolved, origin: true}
+	for _, name := range []m.Name{{Path: "a", Base: "x"}, {Path: "a", Base: "y"}, {Path: "b", Base: "z"}} {
+		tree.add(&m.File{Id: m.Id{Root: "origin", Name: name}}, resolved)
+	}
+	if counted != 0 {
+		t.Errorf("Expected no presence counted while adding, counted %d files", counted)
+	}
+	tree.find("a").presenceOf()
+	if counted != 2 {
+		t.Errorf("Expected the presence of the 2 files in a, counted %d files", counted)
+	}
+}
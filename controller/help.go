@@ -0,0 +1,39 @@
+package controller
+
+import (
+	w "arch/widgets"
+	"strings"
+)
+
+func (c *controller) toggleHelp() {
+	if c.pane != nil {
+		c.pane = nil
+		return
+	}
+	if c.dialog != nil {
+		return
+	}
+	sections := []w.HelpSection{}
+	for _, section := range c.keys.Sections() {
+		help := w.HelpSection{Title: section.Title}
+		for _, action := range section.Actions {
+			bound := strings.Join(action.Keys, ", ")
+			if bound == "" {
+				bound = "unbound"
+			}
+			help.Lines = append(help.Lines, w.HelpLine{Keys: bound, Help: action.Help})
+		}
+		sections = append(sections, help)
+	}
+	c.pane = &w.PaneInfo{Title: "Help", Rows: w.HelpRows(sections)}
+}
+
+func (c *controller) scrollPane(lines int) {
+	c.pane.Offset += lines
+	if last := len(c.pane.Rows) - c.view.ScreenSize.Height + 2; c.pane.Offset > last {
+		c.pane.Offset = last
+	}
+	if c.pane.Offset < 0 {
+		c.pane.Offset = 0
+	}
+}
@@ -0,0 +1,485 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+type keepRule func(c *controller, a, b *m.File) bool
+
+// keepRules choose the copy to keep among the origin files, or among the copies in every
+// root if the origin has none. A rule returns true if a is preferred to b.
+var keepRules = map[string]keepRule{
+	"current-path":  currentPathFirst,
+	"shortest-path": shortestPathFirst,
+	"newest":        newestFirst,
+}
+
+const defaultKeepRule = "current-path"
+
+func (c *controller) setKeepRule(name string) {
+	if name == "" {
+		name = defaultKeepRule
+	}
+	rule, ok := keepRules[name]
+	if !ok {
+		log.Printf("### unknown keep rule %q, using %q", name, defaultKeepRule)
+		name, rule = defaultKeepRule, keepRules[defaultKeepRule]
+	}
+	c.keepRuleName, c.keepRule = name, rule
+}
+
+// currentPathFirst prefers copies inside the current folder, so that keeping all in
+// a folder keeps the files where they are listed.
+func currentPathFirst(c *controller, a, b *m.File) bool {
+	aInside, bInside := inFolder(a.Path, c.currentPath), inFolder(b.Path, c.currentPath)
+	if aInside != bInside {
+		return aInside
+	}
+	return shortestPathFirst(c, a, b)
+}
+
+// shortestPathFirst prefers the least nested and shortest names, then the origin copy.
+func shortestPathFirst(c *controller, a, b *m.File) bool {
+	aDepth, bDepth := strings.Count(a.Path.String(), "/"), strings.Count(b.Path.String(), "/")
+	if a.Path == "" {
+		aDepth = -1
+	}
+	if b.Path == "" {
+		bDepth = -1
+	}
+	if aDepth != bDepth {
+		return aDepth < bDepth
+	}
+	aName, bName := a.Name.String(), b.Name.String()
+	if len(aName) != len(bName) {
+		return len(aName) < len(bName)
+	}
+	if aOrigin, bOrigin := a.Root == c.origin, b.Root == c.origin; aOrigin != bOrigin {
+		return aOrigin
+	}
+	if aName != bName {
+		return aName < bName
+	}
+	return a.Root < b.Root
+}
+
+func newestFirst(c *controller, a, b *m.File) bool {
+	if !a.ModTime.Equal(b.ModTime) {
+		return a.ModTime.After(b.ModTime)
+	}
+	return shortestPathFirst(c, a, b)
+}
+
+func inFolder(path, folder m.Path) bool {
+	return folder == "" || path == folder || strings.HasPrefix(path.String(), folder.String()+"/")
+}
+
+// keepPlan collects the files to keep and the extra files to delete, and then plans the
+// commands which the confirmation dialog counts and executeKeepPlan sends.
+type keepPlan struct {
+	path      m.Path
+	marked    bool
+	files     []*m.File
+	extras    []*m.File
+	extraIds  map[m.Id]struct{}
+	hashes    map[m.Hash]struct{}
+	steps     []keepStep
+	kept      int
+	copies    int
+	renames   int
+	deletes   int
+	missing   int
+	spared    int
+	copyBytes uint64
+}
+
+// keepStep holds the commands planned for a hash, which becomes pending when they are sent.
+// The deletes of missing steps delete content which the origin does not have.
+type keepStep struct {
+	hash    m.Hash
+	cmds    []m.FileCommand
+	missing bool
+}
+
+func newKeepPlan(path m.Path) *keepPlan {
+	return &keepPlan{path: path, hashes: map[m.Hash]struct{}{}, extraIds: map[m.Id]struct{}{}}
+}
+
+func (c *controller) keepAll() {
+	if !c.archivesScanned {
+		return
+	}
+	plan := newKeepPlan(c.currentPath)
+	c.planFolder(plan, c.currentPath, false)
+	c.confirmKeepPlan("Keep all in "+folderName(c.currentPath), plan)
+}
+
+func (c *controller) keepFolder(path m.Path) {
+	if !c.archivesScanned {
+		return
+	}
+	plan := newKeepPlan(path)
+	c.planFolder(plan, path, true)
+	c.confirmKeepPlan("Sync folder "+folderName(path), plan)
+}
+
+// planFolder keeps every inconsistent content having a copy in the folder, choosing the
+// copy by the keep rule. Mirroring makes the copy roots match the origin folder instead:
+// it keeps the origin files in the folder, and moves copies in the folder to the origin
+// files of their content outside the folder. Copies of content the origin does not have
+// are deleted, see planCommands.
+func (c *controller) planFolder(plan *keepPlan, path m.Path, mirror bool) {
+	for hash, files := range c.files {
+		if c.state[hash] == w.Pending {
+			continue
+		}
+		var winner *m.File
+		if mirror {
+			winner = c.originWinner(files, path)
+			if winner == nil && hasFileIn(files, path) {
+				if file := c.keepWinner(files); file.Root == c.origin {
+					winner = file
+				}
+			}
+		} else if hasFileIn(files, path) {
+			winner = c.keepWinner(files)
+		}
+		if winner == nil {
+			if !mirror {
+				continue
+			}
+			for _, file := range files {
+				if file.Root != c.origin && inFolder(file.Path, path) {
+					plan.addExtra(file)
+				}
+			}
+			continue
+		}
+		if c.inconsistent(files) {
+			plan.addFile(winner)
+		}
+	}
+}
+
+// keepWinner picks the copy to keep by the keep rule. Origin files win over the copies,
+// so that keeping never renames origin files after their copies. Of content absent from
+// the origin, copies whose name no other content has win, so that keeping them renames
+// nothing.
+func (c *controller) keepWinner(files []*m.File) *m.File {
+	var winner *m.File
+	for _, file := range files {
+		if winner == nil || c.preferred(file, winner) {
+			winner = file
+		}
+	}
+	return winner
+}
+
+func (c *controller) preferred(a, b *m.File) bool {
+	aOrigin, bOrigin := a.Root == c.origin, b.Root == c.origin
+	if aOrigin != bOrigin {
+		return aOrigin
+	}
+	if !aOrigin {
+		if aFree, bFree := !c.nameClash(a), !c.nameClash(b); aFree != bFree {
+			return aFree
+		}
+	}
+	return c.keepRule(c, a, b)
+}
+
+// nameClash tells if any root has other content under the name of the file.
+func (c *controller) nameClash(file *m.File) bool {
+	for _, root := range c.roots {
+		if other := c.fileAt(m.Id{Root: root, Name: file.Name}); other != nil && other.Hash != file.Hash {
+			return true
+		}
+	}
+	return false
+}
+
+// originWinner picks the origin file to keep among the ones in the folder, so that
+// mirroring a folder never deletes its own origin files in favour of others.
+func (c *controller) originWinner(files []*m.File, path m.Path) *m.File {
+	var winner *m.File
+	for _, file := range files {
+		if file.Root != c.origin || !inFolder(file.Path, path) {
+			continue
+		}
+		if winner == nil || c.keepRule(c, file, winner) {
+			winner = file
+		}
+	}
+	return winner
+}
+
+func hasFileIn(files []*m.File, path m.Path) bool {
+	for _, file := range files {
+		if inFolder(file.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *keepPlan) addFile(file *m.File) {
+	if _, ok := p.hashes[file.Hash]; ok {
+		return
+	}
+	p.hashes[file.Hash] = struct{}{}
+	p.files = append(p.files, file)
+}
+
+func (p *keepPlan) addExtra(file *m.File) {
+	if _, ok := p.extraIds[file.Id]; ok {
+		return
+	}
+	p.extraIds[file.Id] = struct{}{}
+	p.extras = append(p.extras, file)
+}
+
+// planCommands plans the commands keeping the files in the order of their ids, so that of
+// different contents under the same name always the same one keeps the name. The commands
+// of every file are planned on the model as the commands before them leave it.
+// The extra files, of content the origin does not have, are deleted only if a copy of
+// their content outside of them is left. Otherwise they are the last copies, and are spared.
+func (c *controller) planCommands(plan *keepPlan) {
+	byId := func(files []*m.File) {
+		sort.Slice(files, func(i, j int) bool { return files[i].Id.String() < files[j].Id.String() })
+	}
+	byId(plan.files)
+	idx := newPlanIndex(c)
+	for _, file := range plan.files {
+		file = idx.plannedFile(file)
+		if cmds := c.keepCommands(idx, file); len(cmds) > 0 {
+			plan.kept++
+			plan.addStep(idx, keepStep{hash: file.Hash, cmds: cmds})
+		}
+	}
+
+	byId(plan.extras)
+	extras := map[m.Hash][]m.FileCommand{}
+	hashes := []m.Hash{}
+	for _, file := range plan.extras {
+		file = idx.plannedFile(file)
+		if _, ok := extras[file.Hash]; !ok {
+			hashes = append(hashes, file.Hash)
+		}
+		extras[file.Hash] = append(extras[file.Hash], m.DeleteFile{Id: file.Id, Hash: file.Hash})
+	}
+	for _, hash := range hashes {
+		cmds := extras[hash]
+		if len(idx.filesOf(hash)) == len(cmds) {
+			plan.spared += len(cmds)
+			continue
+		}
+		plan.addStep(idx, keepStep{hash: hash, cmds: cmds, missing: true})
+	}
+}
+
+func (p *keepPlan) addStep(idx *planIndex, step keepStep) {
+	for _, cmd := range step.cmds {
+		switch cmd := cmd.(type) {
+		case m.CopyFile:
+			p.copies += len(cmd.To)
+			p.copyBytes += idx.fileAt(cmd.From).Size * uint64(len(cmd.To))
+		case m.RenameFile:
+			p.renames++
+		case m.DeleteFile:
+			if step.missing {
+				p.missing++
+			} else {
+				p.deletes++
+			}
+		}
+		idx.apply(cmd)
+	}
+	p.steps = append(p.steps, step)
+}
+
+func (c *controller) inconsistent(files []*m.File) bool {
+	originFiles := 0
+	names := map[m.Name]struct{}{}
+	for _, file := range files {
+		if file.Root == c.origin {
+			originFiles++
+		}
+		names[file.Name] = struct{}{}
+	}
+	return originFiles > 1 || len(files) != len(c.roots) || len(names) != 1
+}
+
+func (c *controller) confirmKeepPlan(title string, plan *keepPlan) {
+	c.planCommands(plan)
+	if len(plan.steps) == 0 {
+		return
+	}
+	lines := []string{
+		fmt.Sprintf("Keep %d files using the %q rule.", plan.kept, c.keepRuleName),
+		fmt.Sprintf("Copy %d files (%s bytes).", plan.copies, strings.TrimSpace(w.FormatSize(plan.copyBytes))),
+		fmt.Sprintf("Rename %d files.", plan.renames),
+		fmt.Sprintf("Delete %d files.", plan.deletes),
+	}
+	if plan.missing > 0 {
+		lines = append(lines, fmt.Sprintf("Delete %d files missing from the origin.", plan.missing))
+	}
+	if plan.spared > 0 {
+		lines = append(lines, fmt.Sprintf("Leave %d files missing from the origin, they are the last copies.", plan.spared))
+	}
+	c.showDialog(title, lines, "Keep", func() { c.executeKeepPlan(plan) })
+}
+
+// executeKeepPlan sends the planned commands, the ones the confirmation dialog counted.
+func (c *controller) executeKeepPlan(plan *keepPlan) {
+	sync := &folderSync{path: plan.path, hashes: map[m.Hash]struct{}{}}
+	c.sendBatch(func() {
+		for _, step := range plan.steps {
+			for _, cmd := range step.cmds {
+				c.apply(cmd)
+			}
+			c.setState(step.hash, w.Pending)
+			sync.hashes[step.hash] = struct{}{}
+		}
+	})
+	sync.total = len(sync.hashes)
+	c.sync = sync
+	if plan.marked {
+		c.clearMarks()
+	}
+}
+
+func folderName(path m.Path) string {
+	if path == "" {
+		return "Root"
+	}
+	return path.String()
+}
+
+type folderSync struct {
+	path   m.Path
+	hashes map[m.Hash]struct{}
+	total  int
+}
+
+func (c *controller) syncProgress() *w.ProgressInfo {
+	if c.sync == nil {
+		return nil
+	}
+	pending := 0
+	for hash := range c.sync.hashes {
+		if c.state[hash] == w.Pending {
+			pending++
+		}
+	}
+	if pending == 0 {
+		c.sync = nil
+		return nil
+	}
+	return &w.ProgressInfo{
+		Root:  m.Root(folderName(c.sync.path)),
+		Tab:   " Syncing",
+		Value: float64(c.sync.total-pending) / float64(c.sync.total),
+	}
+}
+
+// planIndex is the model as the planned commands leave it. The files and lists which
+// the commands change are copied, so that planning leaves the model as it is.
+type planIndex struct {
+	c       *controller
+	files   map[m.Hash][]*m.File
+	byId    map[m.Id]*m.File
+	planned map[*m.File]*m.File
+	owned   map[*m.File]struct{}
+}
+
+func newPlanIndex(c *controller) *planIndex {
+	return &planIndex{
+		c:       c,
+		files:   map[m.Hash][]*m.File{},
+		byId:    map[m.Id]*m.File{},
+		planned: map[*m.File]*m.File{},
+		owned:   map[*m.File]struct{}{},
+	}
+}
+
+func (p *planIndex) filesOf(hash m.Hash) []*m.File {
+	if files, ok := p.files[hash]; ok {
+		return files
+	}
+	return p.c.files[hash]
+}
+
+func (p *planIndex) fileAt(id m.Id) *m.File {
+	if file, ok := p.byId[id]; ok {
+		return file
+	}
+	return p.c.fileAt(id)
+}
+
+// plannedFile returns the file of the model as the planned commands leave it.
+func (p *planIndex) plannedFile(file *m.File) *m.File {
+	if planned, ok := p.planned[file]; ok {
+		return planned
+	}
+	return file
+}
+
+func (p *planIndex) ownFiles(hash m.Hash) []*m.File {
+	files, ok := p.files[hash]
+	if !ok {
+		files = append([]*m.File{}, p.c.files[hash]...)
+		p.files[hash] = files
+	}
+	return files
+}
+
+func (p *planIndex) apply(cmd m.FileCommand) {
+	switch cmd := cmd.(type) {
+	case m.RenameFile:
+		files := p.ownFiles(cmd.Hash)
+		for i, file := range files {
+			if file.Id != cmd.From {
+				continue
+			}
+			if _, ok := p.owned[file]; !ok {
+				moved := *file
+				p.planned[file] = &moved
+				p.owned[&moved] = struct{}{}
+				files[i] = &moved
+			}
+			files[i].Id = cmd.To
+			p.byId[cmd.From] = nil
+			p.byId[cmd.To] = files[i]
+			return
+		}
+
+	case m.DeleteFile:
+		files := p.ownFiles(cmd.Hash)
+		for i, file := range files {
+			if file.Id == cmd.Id {
+				p.files[cmd.Hash] = append(files[:i], files[i+1:]...)
+				p.byId[cmd.Id] = nil
+				return
+			}
+		}
+
+	case m.CopyFile:
+		source := p.fileAt(cmd.From)
+		if source == nil {
+			return
+		}
+		files := p.ownFiles(cmd.Hash)
+		for _, id := range cmd.To {
+			file := &m.File{Id: id, Size: source.Size, ModTime: source.ModTime, Hash: source.Hash}
+			p.owned[file] = struct{}{}
+			p.byId[id] = file
+			files = append(files, file)
+		}
+		p.files[cmd.Hash] = files
+	}
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"fmt"
+)
+
+func (c *controller) toggleMark() {
+	selected := c.selectedEntry()
+	if selected == nil {
+		return
+	}
+	if _, ok := c.marked[selected.Id]; ok {
+		delete(c.marked, selected.Id)
+	} else {
+		c.marked[selected.Id] = selected
+	}
+	c.moveSelection(1)
+}
+
+func (c *controller) extendMark(lines int) {
+	c.mark(c.selectedEntry())
+	c.moveSelection(lines)
+	c.mark(c.selectedEntry())
+}
+
+func (c *controller) mark(entry *w.File) {
+	if entry != nil {
+		c.marked[entry.Id] = entry
+	}
+}
+
+func (c *controller) markByState() {
+	selected := c.selectedEntry()
+	if selected == nil {
+		return
+	}
+	for _, entry := range c.view.Entries {
+		if entry.State == selected.State {
+			c.mark(entry)
+		}
+	}
+}
+
+func (c *controller) clearMarks() {
+	c.marked = map[m.Id]*w.File{}
+}
+
+func (c *controller) mouseDrag(cmd any) {
+	id, ok := cmd.(m.SelectFile)
+	if !ok {
+		return
+	}
+	from, to := -1, -1
+	for idx, entry := range c.view.Entries {
+		if entry.Id == c.dragAnchor {
+			from = idx
+		}
+		if entry.Id == m.Id(id) {
+			to = idx
+		}
+	}
+	if from < 0 || to < 0 {
+		return
+	}
+	if from > to {
+		from, to = to, from
+	}
+	for _, entry := range c.view.Entries[from : to+1] {
+		c.mark(entry)
+	}
+	c.currentFolder().selectedId = m.Id(id)
+	c.makeSelectedVisible()
+}
+
+func (c *controller) markedEntries() []*w.File {
+	entries := make([]*w.File, 0, len(c.marked))
+	for _, entry := range c.marked {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (c *controller) keepMarked() {
+	if !c.archivesScanned {
+		return
+	}
+	plan := newKeepPlan(c.currentPath)
+	plan.marked = true
+	for _, entry := range c.markedEntries() {
+		if entry.Kind == w.FileFolder {
+			c.planFolder(plan, m.Path(entry.Name.String()), true)
+		} else if file := c.file(entry.Hash, entry.Id); file != nil && c.state[file.Hash] != w.Pending {
+			plan.addFile(file)
+		}
+	}
+	c.confirmKeepPlan(fmt.Sprintf("Keep %d marked entries", len(c.marked)), plan)
+}
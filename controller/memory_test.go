@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"arch/config"
+	"arch/files/mock_fs"
+	"arch/keys"
+	m "arch/model"
+	"arch/renderer/text"
+	"arch/stream"
+	w "arch/widgets"
+	"flag"
+	"io"
+	"log"
+	"runtime"
+	"testing"
+)
+
+var benchFiles = flag.Int("files", 20_000, "files per root of the generated archive in benchmarks")
+
+var benchRoots = []m.Root{"origin", "copy"}
+
+// generatedFs simulates an origin and one copy of generated files.
+func generatedFs(b *testing.B) (m.FS, *stream.Stream[m.Event]) {
+	prevLog := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(prevLog) })
+	b.Setenv("HOME", b.TempDir())
+	b.Setenv("XDG_CONFIG_HOME", b.TempDir())
+	mock_fs.Delay = 0
+	events := stream.NewStream[m.Event]("bench")
+	return mock_fs.NewGeneratedFs(events, benchRoots, *benchFiles, 1), events
+}
+
+// scanFs returns a controller which has scanned the roots of the file system.
+func scanFs(b *testing.B, fs m.FS, events *stream.Stream[m.Event]) *controller {
+	registry, err := keys.New(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := newController(text.NewRenderer(), events, benchRoots, &config.Config{}, registry)
+	c.scanArchives(fs)
+	c.handleEvent(m.ScreenSize{Width: 100, Height: 30})
+	for !c.archivesScanned || c.stateCounts[w.Pending] > 0 {
+		for _, event := range events.Pull() {
+			c.handleEvent(event)
+		}
+		c.render()
+	}
+	c.closeArchives()
+	return c
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkScanMemory reports the heap the controller keeps per scanned file. The simulated
+// roots are made before measuring, so their files are not counted.
+// Run it with: go test ./controller -run NONE -bench ScanMemory -benchtime 1x -files 5000000
+func BenchmarkScanMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fs, events := generatedFs(b)
+		before := heapAlloc()
+		c := scanFs(b, fs, events)
+		after := heapAlloc()
+		files := 0
+		c.every(func(*m.File) { files++ })
+		b.ReportMetric(float64(after-before)/float64(files), "B/file")
+		runtime.KeepAlive(c)
+		runtime.KeepAlive(fs)
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	fs, events := generatedFs(b)
+	c := scanFs(b, fs, events)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.markDirty(m.MoveSelection{Lines: 1})
+		c.render()
+	}
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+)
+
+func (c *controller) populatePresence() {
+	if !c.showPresence {
+		return
+	}
+	folders := map[m.Base]*w.File{}
+	for _, entry := range c.view.Entries {
+		entry.Presence = make([]w.Presence, len(c.roots))
+		if entry.Kind == w.FileFolder {
+			folders[entry.Base] = entry
+		} else {
+			c.addPresence(entry.Presence, c.files[entry.Hash], entry.Name)
+		}
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	node := c.tree.find(c.currentPath)
+	if node == nil {
+		return
+	}
+	initial := c.archives[c.origin].progressState == m.Initial
+	for name, folder := range folders {
+		if child, ok := node.folders[name]; ok {
+			for b, presence := range child.presenceOf() {
+				if b.origin || !initial {
+					sumPresence(folder.Presence, presence)
+				}
+			}
+		}
+	}
+}
+
+// filePresence tells how the content of the file is present in each root.
+func (c *controller) filePresence(file *m.File) []w.Presence {
+	presence := make([]w.Presence, len(c.roots))
+	c.addPresence(presence, c.files[file.Hash], file.Name)
+	return presence
+}
+
+func (c *controller) addPresence(presence []w.Presence, files []*m.File, name m.Name) {
+	for idx, root := range c.roots {
+		count, named := 0, false
+		for _, file := range files {
+			if file.Root == root {
+				count++
+				named = named || file.Name == name
+			}
+		}
+		switch {
+		case count == 0:
+			presence[idx].Missing++
+		case named:
+			presence[idx].Present++
+		default:
+			presence[idx].Misnamed++
+		}
+		if count > 1 {
+			presence[idx].Duplicated += count - 1
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	w "arch/widgets"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -30,25 +31,30 @@ func (c *controller) autoresolve() {
 		for len(parts) > 0 {
 			name := filepath.Join(parts...)
 			if file.Root == c.origin {
-				originNames[name] = ""
+				originNames[name] = m.Hash{}
 			}
 			allNames[name] = struct{}{}
 			parts = parts[:len(parts)-1]
 		}
 	})
+	conflicts := []*m.File{}
 	c.every(func(file *m.File) {
 		if file.Root == c.origin {
 			return
 		}
 		if originHash, ok := originNames[file.Name.String()]; ok && originHash != file.Hash {
-			newName := uniqueName(allNames, renamings, file.Name, file.Hash)
-			newId := m.Id{Root: file.Root, Name: newName}
-			c.archives[c.origin].scanner.Send(m.RenameFile{From: file.Id, To: newId, Hash: file.Hash})
-			file.Id = newId
-			allNames[newId.Name.String()] = struct{}{}
-			pending[file.Hash] = struct{}{}
+			conflicts = append(conflicts, file)
 		}
 	})
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Id.String() < conflicts[j].Id.String()
+	})
+	for _, file := range conflicts {
+		newName := uniqueName(allNames, renamings, file.Name, file.Hash)
+		newId := m.Id{Root: file.Root, Name: newName}
+		c.apply(m.RenameFile{From: file.Id, To: newId, Hash: file.Hash})
+		pending[file.Hash] = struct{}{}
+	}
 
 	for _, files := range c.files {
 		originFiles := []*m.File{}
@@ -64,7 +70,7 @@ func (c *controller) autoresolve() {
 		}
 	}
 	for hash := range pending {
-		c.state[hash] = w.Pending
+		c.setState(hash, w.Pending)
 	}
 }
 
@@ -73,6 +79,17 @@ func uniqueName(allNames map[string]struct{}, renamings map[namehash]m.Name, nam
 	if newName, ok := renamings[nh]; ok {
 		return newName
 	}
+	newName := freeName(name, func(name m.Name) bool {
+		_, ok := allNames[name.String()]
+		return ok
+	})
+	allNames[newName.String()] = struct{}{}
+	renamings[nh] = newName
+	return newName
+}
+
+// freeName numbers the name like "name [1].ext" until it is not taken.
+func freeName(name m.Name, taken func(name m.Name) bool) m.Name {
 	parts := strings.Split(name.Base.String(), ".")
 
 	var part string
@@ -91,9 +108,7 @@ func uniqueName(allNames map[string]struct{}, renamings map[namehash]m.Name, nam
 		}
 
 		newName := m.Name{Path: name.Path, Base: m.Base(newBase)}
-		if _, ok := allNames[newName.String()]; !ok {
-			allNames[newName.String()] = struct{}{}
-			renamings[nh] = newName
+		if !taken(newName) {
 			return newName
 		}
 	}
@@ -3,18 +3,19 @@ package controller
 import (
 	m "arch/model"
 	w "arch/widgets"
-	"strings"
 )
 
 type nameHashPair struct {
 	m.Name
 	m.Hash
 }
-type nameHashSet map[nameHashPair]struct{}
 
 func (c *controller) buildView() *w.View {
-	nameHashes := nameHashSet{}
-	c.populateEntries(nameHashes)
+	if c.entriesDirty {
+		c.entriesDirty = false
+		c.populateEntries()
+		c.stats()
+	}
 
 	folder := c.currentFolder()
 
@@ -49,17 +50,39 @@ func (c *controller) buildView() *w.View {
 	c.view.OffsetIdx = folder.offsetIdx
 	c.view.SortColumn = folder.sortColumn
 	c.view.SortAscending = folder.sortAscending
-
-	c.stats()
+	c.view.Filter = c.filter
+	for _, entry := range c.view.Entries {
+		_, entry.Marked = c.marked[entry.Id]
+	}
+	c.view.MarkedFiles = len(c.marked)
+	c.view.Details = c.details()
+	c.view.Roots = nil
+	if c.showPresence {
+		c.view.Roots = c.roots
+		c.populatePresence()
+	}
+	c.view.Dialog = c.dialog.info()
+	c.view.Pane = c.pane
+	c.view.Search = nil
+	if c.search != nil {
+		c.view.Search = c.search.info()
+	}
+	c.view.FlatPaths = c.flatView()
+	c.view.Problems = c.problems
 	return &c.view
 }
 
-func (c *controller) populateEntries(nameHashes nameHashSet) {
+func (c *controller) populateEntries() {
+	c.updateTree()
 	c.view.Entries = c.view.Entries[:0]
-	for hash, files := range c.files {
-		state := c.calcState(hash, files)
-		c.state[hash] = state
-		c.addEntries(state, files, nameHashes)
+	if c.flatView() {
+		c.addFlatEntries()
+	} else {
+		c.addEntries()
+		c.addPinnedCopy()
+	}
+	if c.search != nil && !c.search.recursive {
+		c.filterEntries()
 	}
 	c.sort()
 }
@@ -83,62 +106,85 @@ func (c *controller) calcState(hash m.Hash, files []*m.File) w.State {
 	return w.Resolved
 }
 
-func (c *controller) addEntries(state w.State, files []*m.File, nameHashes nameHashSet) {
-	originProgressState := c.archives[c.origin].progressState
-	for _, file := range files {
-		if file.Root != c.origin && (originProgressState == m.Initial || state != w.Absent) {
-			continue
+func (c *controller) addEntries() {
+	node := c.tree.find(c.currentPath)
+	if node == nil {
+		return
+	}
+	for _, l := range node.files {
+		if c.listed(l.bucket) {
+			c.view.Entries = append(c.view.Entries, c.fileEntry(l))
 		}
-		nameHash := nameHashPair{Name: file.Name, Hash: file.Hash}
-
-		if file.Path == c.currentPath {
-			if _, ok := nameHashes[nameHash]; !ok {
-				c.view.Entries = append(c.view.Entries, &w.File{
-					File:  *file,
-					Kind:  w.FileRegular,
-					State: state,
-				})
+	}
+	for name, child := range node.folders {
+		entry := &w.File{
+			File: m.File{Id: m.Id{Name: m.Name{Path: c.currentPath, Base: name}}},
+			Kind: w.FileFolder,
+		}
+		var folderTotal total
+		for b, childTotal := range child.totalsOf() {
+			if childTotal.count == 0 || !c.listed(b) {
+				continue
 			}
-		} else if strings.HasPrefix(file.Path.String(), c.currentPath.String()) {
-			relPath := file.Path
-			if len(c.currentPath) > 0 {
-				relPath = file.Path[len(c.currentPath)+1:]
+			folderTotal = folderTotal.add(childTotal)
+			if entry.State < b.state {
+				entry.State = b.state
 			}
-			name := m.Base(strings.SplitN(relPath.String(), "/", 2)[0])
+		}
+		if folderTotal.count > 0 {
+			entry.Size, entry.ModTime = folderTotal.size, folderTotal.modTime
+			c.view.Entries = append(c.view.Entries, entry)
+		}
+	}
+}
 
-			i, found := m.Find(c.view.Entries, func(entry *w.File) bool {
-				return name == entry.Base && entry.Kind == w.FileFolder
-			})
-			if found {
-				c.view.Entries[i].Size += file.Size
-				if c.view.Entries[i].ModTime.Before(file.ModTime) {
-					c.view.Entries[i].ModTime = file.ModTime
-				}
-				if c.view.Entries[i].State < state {
-					c.view.Entries[i].State = state
+func (c *controller) addFlatEntries() {
+	for _, node := range c.tree.folders {
+		for _, l := range node.files {
+			if !c.listed(l.bucket) || c.problems && l.state == w.Resolved && !l.failed {
+				continue
+			}
+			entry := c.fileEntry(l)
+			if c.search != nil && c.search.recursive {
+				if c.search.match == nil {
+					continue
 				}
-			} else {
-				entry := &w.File{
-					File: m.File{
-						Id: m.Id{
-							Name: m.Name{
-								Path: c.currentPath,
-								Base: name,
-							},
-						},
-						Size:    file.Size,
-						ModTime: file.ModTime,
-					},
-					Kind:  w.FileFolder,
-					State: state,
+				match, ok := c.search.match(l.file.Base.String())
+				if !ok {
+					continue
 				}
-				c.view.Entries = append(c.view.Entries, entry)
+				entry.Match = match
 			}
+			c.view.Entries = append(c.view.Entries, entry)
 		}
-		nameHashes[nameHash] = struct{}{}
 	}
 }
 
+func (c *controller) fileEntry(l leaf) *w.File {
+	return &w.File{
+		File:   *l.file,
+		Kind:   w.FileRegular,
+		State:  l.state,
+		Copies: len(c.files[l.file.Hash]),
+	}
+}
+
+func (c *controller) passesFilter(state w.State, failed bool) bool {
+	switch c.filter {
+	case w.FilterProblems:
+		return state != w.Resolved || failed
+	case w.FilterDuplicate:
+		return state == w.Duplicate
+	case w.FilterAbsent:
+		return state == w.Absent
+	case w.FilterPending:
+		return state == w.Pending
+	case w.FilterFailed:
+		return failed
+	}
+	return true
+}
+
 func (c *controller) progress() []w.ProgressInfo {
 	infos := []w.ProgressInfo{}
 	archive := c.archives[c.origin]
@@ -151,6 +197,9 @@ func (c *controller) progress() []w.ProgressInfo {
 			TimeRemaining: c.timeRemaining,
 		})
 	}
+	if sync := c.syncProgress(); sync != nil {
+		infos = append(infos, *sync)
+	}
 	var tab string
 	var value float64
 	for _, root := range c.roots {
@@ -172,18 +221,9 @@ func (c *controller) progress() []w.ProgressInfo {
 }
 
 func (c *controller) stats() {
-	c.view.PendingFiles, c.view.DuplicateFiles, c.view.AbsentFiles = 0, 0, 0
-
-	for _, presence := range c.state {
-		switch presence {
-		case w.Pending:
-			c.view.PendingFiles++
-		case w.Duplicate:
-			c.view.DuplicateFiles++
-		case w.Absent:
-			c.view.AbsentFiles++
-		}
-	}
+	c.view.PendingFiles = c.stateCounts[w.Pending]
+	c.view.DuplicateFiles = c.stateCounts[w.Duplicate]
+	c.view.AbsentFiles = c.stateCounts[w.Absent]
 	if c.archives[c.origin].progressState == m.Initial {
 		c.view.AbsentFiles = 0
 	}
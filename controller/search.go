@@ -0,0 +1,171 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type search struct {
+	query     string
+	kind      w.SearchKind
+	recursive bool
+	focused   bool
+	match     func(name string) (w.Match, bool)
+	err       error
+}
+
+func (c *controller) startSearch(recursive bool) {
+	if c.search != nil && c.search.recursive == recursive {
+		c.search.focused = true
+		return
+	}
+	c.search = &search{recursive: recursive, focused: true}
+	c.search.compile()
+	c.searchResults = nil
+}
+
+func (c *controller) handleTextInput(event any) {
+	s := c.search
+	if s == nil || !s.focused {
+		return
+	}
+	switch event := event.(type) {
+	case m.TextInput:
+		s.query += string(event.Rune)
+
+	case m.TextBackspace:
+		if s.query != "" {
+			_, size := utf8.DecodeLastRuneInString(s.query)
+			s.query = s.query[:len(s.query)-size]
+		}
+
+	case m.TextTab:
+		s.kind = (s.kind + 1) % (w.SearchRegex + 1)
+
+	case m.TextEnter:
+		s.focused = false
+		if s.recursive {
+			c.jumpToSelected()
+		}
+		return
+
+	case m.TextCancel:
+		c.search = nil
+		return
+	}
+	s.compile()
+	c.currentFolder().offsetIdx = 0
+}
+
+func (c *controller) jumpToSelected() {
+	selected := c.selectedEntry()
+	c.search = nil
+	c.problems = false
+	if selected == nil {
+		return
+	}
+	c.currentPath = selected.Path
+	c.currentFolder().selectedId = selected.Id
+}
+
+func (c *controller) clearFolderSearch() {
+	if c.search != nil && !c.search.recursive {
+		c.search = nil
+	}
+}
+
+func (s *search) compile() {
+	s.err = nil
+	query := strings.ToLower(s.query)
+	switch s.kind {
+	case w.SearchSubstring:
+		query := s.query
+		s.match = func(name string) (w.Match, bool) {
+			return foldMatch(name, query)
+		}
+
+	case w.SearchGlob:
+		if !strings.ContainsAny(query, "*?[") {
+			query = "*" + query + "*"
+		}
+		if _, err := filepath.Match(query, ""); err != nil {
+			s.err = err
+			s.match = nil
+			return
+		}
+		s.match = func(name string) (w.Match, bool) {
+			if ok, _ := filepath.Match(query, strings.ToLower(name)); ok {
+				return w.Match{Start: 0, End: utf8.RuneCountInString(name)}, true
+			}
+			return w.Match{}, false
+		}
+
+	case w.SearchRegex:
+		re, err := regexp.Compile("(?i)" + s.query)
+		if err != nil {
+			s.err = err
+			s.match = nil
+			return
+		}
+		s.match = func(name string) (w.Match, bool) {
+			loc := re.FindStringIndex(name)
+			if loc == nil {
+				return w.Match{}, false
+			}
+			return runeMatch(name, loc[0], loc[1]), true
+		}
+	}
+}
+
+// foldMatch finds the query in the name ignoring case. Windows of the name are
+// compared rune by rune, so the match counts the runes of the name itself.
+func foldMatch(name, query string) (w.Match, bool) {
+	length := utf8.RuneCountInString(query)
+	starts := make([]int, 0, len(name)+1)
+	for idx := range name {
+		starts = append(starts, idx)
+	}
+	starts = append(starts, len(name))
+	for i := 0; i+length < len(starts); i++ {
+		if strings.EqualFold(name[starts[i]:starts[i+length]], query) {
+			return w.Match{Start: i, End: i + length}, true
+		}
+	}
+	return w.Match{}, false
+}
+
+func runeMatch(name string, start, end int) w.Match {
+	return w.Match{Start: utf8.RuneCountInString(name[:start]), End: utf8.RuneCountInString(name[:end])}
+}
+
+func (c *controller) filterEntries() {
+	s := c.search
+	if s == nil || s.match == nil {
+		return
+	}
+	entries := c.view.Entries[:0]
+	for _, entry := range c.view.Entries {
+		if match, ok := s.match(entry.Base.String()); ok {
+			entry.Match = match
+			entries = append(entries, entry)
+		}
+	}
+	c.view.Entries = entries
+}
+
+func (s *search) info() *w.SearchInfo {
+	info := &w.SearchInfo{
+		Query:     s.query,
+		Kind:      s.kind,
+		Recursive: s.recursive,
+		Focused:   s.focused,
+	}
+	if s.err != nil {
+		info.Error = s.err.Error()
+	}
+	return info
+}
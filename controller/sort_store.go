@@ -0,0 +1,78 @@
+package controller
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type sortOrder struct {
+	Column    string          `json:"column"`
+	Ascending map[string]bool `json:"ascending"`
+}
+
+type sortStore struct {
+	path   string
+	origin string
+	orders map[string]map[string]sortOrder
+}
+
+func loadSortStore(path string, origin m.Root) *sortStore {
+	store := &sortStore{
+		path:   path,
+		origin: origin.String(),
+		orders: map[string]map[string]sortOrder{},
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return store
+	}
+	if err := json.Unmarshal(data, &store.orders); err != nil {
+		log.Printf("### failed to read sort orders: %v", err)
+	}
+	return store
+}
+
+func (s *sortStore) restore(path m.Path, f *folder) {
+	if s == nil {
+		return
+	}
+	order, ok := s.orders[s.origin][path.String()]
+	if !ok {
+		return
+	}
+	for column := w.SortColumn(0); int(column) < w.SortColumns; column++ {
+		if order.Column == column.String() {
+			f.sortColumn = column
+		}
+		if ascending, ok := order.Ascending[column.String()]; ok {
+			f.sortAscending[column] = ascending
+		}
+	}
+}
+
+func (s *sortStore) save(path m.Path, f *folder) {
+	if s == nil {
+		return
+	}
+	order := sortOrder{Column: f.sortColumn.String(), Ascending: map[string]bool{}}
+	for column, ascending := range f.sortAscending {
+		order.Ascending[w.SortColumn(column).String()] = ascending
+	}
+	if s.orders[s.origin] == nil {
+		s.orders[s.origin] = map[string]sortOrder{}
+	}
+	s.orders[s.origin][path.String()] = order
+
+	data, err := json.MarshalIndent(s.orders, "", "  ")
+	if err == nil {
+		os.MkdirAll(filepath.Dir(s.path), 0755)
+		err = os.WriteFile(s.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("### failed to store sort orders: %v", err)
+	}
+}
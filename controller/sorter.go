@@ -2,6 +2,7 @@ package controller
 
 import (
 	w "arch/widgets"
+	"path/filepath"
 	"sort"
 	"strings"
 )
@@ -17,6 +18,14 @@ func (c *controller) sort() {
 		slice = sliceByTime{sliceBy: files}
 	case w.SortBySize:
 		slice = sliceBySize{sliceBy: files}
+	case w.SortByState:
+		slice = sliceByState{sliceBy: files}
+	case w.SortByPath:
+		slice = sliceByPath{sliceBy: files}
+	case w.SortByExtension:
+		slice = sliceByExtension{sliceBy: files}
+	case w.SortByCopies:
+		slice = sliceByCopies{sliceBy: files}
 	}
 	if !folder.sortAscending[folder.sortColumn] {
 		slice = sort.Reverse(slice)
@@ -95,3 +104,59 @@ func (s sliceBySize) Less(i, j int) bool {
 
 	return s.sliceBy[i].ModTime.Before(s.sliceBy[j].ModTime)
 }
+
+type sliceByState struct {
+	sliceBy
+}
+
+func (s sliceByState) Less(i, j int) bool {
+	iState := s.sliceBy[i].State
+	jState := s.sliceBy[j].State
+	if iState != jState {
+		return iState < jState
+	}
+
+	return strings.ToLower(s.sliceBy[i].Name.String()) < strings.ToLower(s.sliceBy[j].Name.String())
+}
+
+type sliceByPath struct {
+	sliceBy
+}
+
+func (s sliceByPath) Less(i, j int) bool {
+	iName := strings.ToLower(s.sliceBy[i].Name.String())
+	jName := strings.ToLower(s.sliceBy[j].Name.String())
+	if iName != jName {
+		return iName < jName
+	}
+
+	return s.sliceBy[i].Size < s.sliceBy[j].Size
+}
+
+type sliceByExtension struct {
+	sliceBy
+}
+
+func (s sliceByExtension) Less(i, j int) bool {
+	iExt := strings.ToLower(filepath.Ext(s.sliceBy[i].Base.String()))
+	jExt := strings.ToLower(filepath.Ext(s.sliceBy[j].Base.String()))
+	if iExt != jExt {
+		return iExt < jExt
+	}
+
+	return sliceByName(s).Less(i, j)
+}
+
+type sliceByCopies struct {
+	sliceBy
+}
+
+func (s sliceByCopies) Less(i, j int) bool {
+	iCopies := s.sliceBy[i].Copies
+	jCopies := s.sliceBy[j].Copies
+	if iCopies != jCopies {
+		return iCopies < jCopies
+	}
+
+	return sliceByName(s).Less(i, j)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	m "arch/model"
 	"arch/stream"
+	w "arch/widgets"
 	"time"
 )
 
@@ -18,6 +19,8 @@ func (c *controller) handleTick(tick m.Tick) {
 	seconds := dur.Seconds()
 	c.view.FPS = int(float64(c.frames-1) / seconds)
 	c.frames = 0
+	stats := c.events.Stats()
+	c.view.Queue = w.QueueInfo{Len: stats.Len, MaxLen: stats.MaxLen, Capacity: stats.Capacity}
 	copied := c.totalCopiedSize + c.fileCopiedSize - c.prevCopied
 	c.copySpeed = float64(copied) / (seconds * 1024 * 1024)
 	c.prevCopied = c.totalCopiedSize + c.fileCopiedSize
@@ -3,7 +3,6 @@ package controller
 import (
 	m "arch/model"
 	w "arch/widgets"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -19,9 +18,18 @@ func (c *controller) mouseTarget(cmd any) {
 		} else {
 			folder.selectedId = m.Id(cmd)
 		}
+		c.dragAnchor = m.Id(cmd)
 		c.lastMouseEventTime = time.Now()
 
+	case m.DialogButton:
+		c.dialogSelect(int(cmd))
+
+	case m.SelectCopy:
+		c.selectCopy(m.Id(cmd))
+
 	case m.SelectFolder:
+		c.search = nil
+		c.problems = false
 		c.currentPath = m.Path(cmd)
 
 	case w.SortColumn:
@@ -30,6 +38,30 @@ func (c *controller) mouseTarget(cmd any) {
 		} else {
 			folder.sortColumn = cmd
 		}
+		c.sortChanged()
+	}
+}
+
+func (c *controller) sortNext() {
+	folder := c.currentFolder()
+	for {
+		folder.sortColumn = (folder.sortColumn + 1) % w.SortColumn(w.SortColumns)
+		if folder.sortColumn != w.SortByPath || c.flatView() {
+			break
+		}
+	}
+	c.sortChanged()
+}
+
+func (c *controller) sortReverse() {
+	folder := c.currentFolder()
+	folder.sortAscending[folder.sortColumn] = !folder.sortAscending[folder.sortColumn]
+	c.sortChanged()
+}
+
+func (c *controller) sortChanged() {
+	if !c.flatView() {
+		c.sortStore.save(c.currentPath, c.currentFolder())
 	}
 }
 
@@ -49,12 +81,19 @@ func (c *controller) selectLast() {
 }
 
 func (c *controller) open() {
-	exec.Command("open", c.selectedEntry().Id.String()).Start()
+	if selected := c.selectedEntry(); selected != nil {
+		c.launch(c.opener.Open, selected.Id.String())
+	}
 }
 
 func (c *controller) enter() {
+	if c.flatView() {
+		c.jumpToSelected()
+		return
+	}
 	file := c.selectedEntry()
 	if file != nil && file.Kind == w.FileFolder {
+		c.clearFolderSearch()
 		c.currentPath = m.Path(file.Name.String())
 	}
 }
@@ -73,6 +112,7 @@ func (c *controller) exit() {
 	if c.currentPath == "" {
 		return
 	}
+	c.clearFolderSearch()
 	parts := strings.Split(c.currentPath.String(), "/")
 	if len(parts) == 1 {
 		c.currentPath = ""
@@ -80,8 +120,18 @@ func (c *controller) exit() {
 	c.currentPath = m.Path(filepath.Join(parts[:len(parts)-1]...))
 }
 
-func (c *controller) revealInFinder() {
-	exec.Command("open", "-R", c.selectedEntry().Id.String()).Start()
+func (c *controller) reveal() {
+	if selected := c.selectedEntry(); selected != nil {
+		c.launch(c.opener.Reveal, selected.Id.String())
+	}
+}
+
+func (c *controller) launch(open func(path string) error, path string) {
+	go func() {
+		if err := open(path); err != nil {
+			c.events.Push(m.OpenFailed{Path: path, Err: err})
+		}
+	}()
 }
 
 func (c *controller) moveSelection(lines int) {
@@ -134,6 +184,8 @@ func (c *controller) tab() {
 		}
 	}
 	id := sameHash[idx].Id
+	c.search = nil
+	c.problems = false
 	c.currentPath = id.Path
 	c.currentFolder().selectedId = id
 
@@ -141,9 +193,31 @@ func (c *controller) tab() {
 }
 
 func (c *controller) keepSelected() {
-	selected := c.selectedEntry()
-	if selected.Kind == w.FileRegular {
-		c.keepFile(&selected.File)
+	if len(c.marked) > 0 {
+		c.keepMarked()
+		return
+	}
+	c.keepEntry(c.selectedEntry())
+}
+
+func (c *controller) keepEntry(entry *w.File) {
+	if entry == nil {
+		return
+	}
+	if entry.Kind == w.FileFolder {
+		c.keepFolder(m.Path(entry.Name.String()))
+	} else {
+		c.keepFile(&entry.File)
+	}
+}
+
+func (c *controller) deleteSelected() {
+	if len(c.marked) > 0 {
+		c.deleteEntries(c.markedEntries())
+		return
+	}
+	if selected := c.selectedEntry(); selected != nil {
+		c.deleteEntries([]*w.File{selected})
 	}
 }
 
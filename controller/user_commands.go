@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"arch/config"
+	m "arch/model"
+	w "arch/widgets"
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func (c *controller) runCommand(name string) {
+	var command *config.Command
+	for i := range c.commands {
+		if c.commands[i].Name == name {
+			command = &c.commands[i]
+		}
+	}
+	if command == nil || len(command.Args) == 0 {
+		return
+	}
+
+	targets := c.commandTargets(command)
+	if len(targets) == 0 {
+		return
+	}
+
+	// The terminal runs on the renderer goroutine, the output comes back as an event.
+	// Without a terminal the output is captured instead.
+	if command.Terminal && c.renderer.Suspend(func() {
+		c.events.Push(m.CommandOutput{Title: command.Name, Lines: runInTerminal(targets)})
+	}) {
+		return
+	}
+	go func() {
+		c.events.Push(m.CommandOutput{Title: command.Name, Lines: runCaptured(targets)})
+	}()
+}
+
+// commandTargets expands the command for the marked entries, or the selected one.
+// Entries expanding to no arguments, like "{copies}" without copies, are skipped.
+func (c *controller) commandTargets(command *config.Command) [][]string {
+	targets := [][]string{}
+	entries := c.markedEntries()
+	if len(entries) == 0 {
+		if selected := c.selectedEntry(); selected != nil {
+			entries = append(entries, selected)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Id.String() < entries[j].Id.String() })
+	for _, entry := range entries {
+		args := expandArgs(command.Args, c.placeholders(entry))
+		if len(args) == 0 {
+			log.Printf("### command %q: no arguments for %s", command.Name, entry.Id)
+			continue
+		}
+		targets = append(targets, args)
+	}
+	return targets
+}
+
+type placeholders struct {
+	path   string
+	root   string
+	hash   string
+	copies []string
+}
+
+// placeholders of a folder refer to the folder in the origin, folders have no hash.
+func (c *controller) placeholders(entry *w.File) placeholders {
+	if entry.Kind == w.FileFolder {
+		result := placeholders{
+			path: filepath.Join(c.origin.String(), entry.Name.String()),
+			root: c.origin.String(),
+		}
+		for _, root := range c.roots {
+			result.copies = append(result.copies, filepath.Join(root.String(), entry.Name.String()))
+		}
+		return result
+	}
+	result := placeholders{
+		path: entry.Id.String(),
+		root: entry.Root.String(),
+		hash: entry.Hash.String(),
+	}
+	for _, file := range c.files[entry.Hash] {
+		result.copies = append(result.copies, file.Id.String())
+	}
+	sort.Strings(result.copies)
+	return result
+}
+
+// expandArgs replaces placeholders in the command arguments. An argument containing
+// "{copies}" expands to one argument per copy, so that paths with spaces stay intact.
+func expandArgs(args []string, p placeholders) []string {
+	replacer := strings.NewReplacer(
+		"{path}", p.path,
+		"{root}", p.root,
+		"{hash}", p.hash,
+	)
+	result := []string{}
+	for _, arg := range args {
+		arg = replacer.Replace(arg)
+		if !strings.Contains(arg, "{copies}") {
+			result = append(result, arg)
+			continue
+		}
+		for _, copy := range p.copies {
+			result = append(result, strings.ReplaceAll(arg, "{copies}", copy))
+		}
+	}
+	return result
+}
+
+func runCaptured(targets [][]string) []string {
+	lines := []string{}
+	for _, args := range targets {
+		lines = append(lines, "$ "+strings.Join(args, " "))
+		output, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		text := strings.TrimRight(strings.ReplaceAll(string(output), "\t", "    "), "\n")
+		if text != "" {
+			lines = append(lines, strings.Split(text, "\n")...)
+		}
+		lines = append(lines, exitStatus(err), "")
+	}
+	return lines
+}
+
+func runInTerminal(targets [][]string) []string {
+	lines := []string{}
+	for _, args := range targets {
+		fmt.Println("$", strings.Join(args, " "))
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		status := exitStatus(cmd.Run())
+		fmt.Println(status)
+		lines = append(lines, "$ "+strings.Join(args, " "), status)
+	}
+	fmt.Print("Press Enter to return")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	return lines
+}
+
+func exitStatus(err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "exit status 0"
+	case errors.As(err, &exitErr):
+		return fmt.Sprintf("exit status %d", exitErr.ExitCode())
+	}
+	return err.Error()
+}
@@ -31,8 +31,7 @@ func (fs *fileFs) NewArchiveScanner(root m.Root) m.ArchiveScanner {
 		commands: stream.NewStream[m.FileCommand](root.String()),
 		lc:       fs.lc,
 		files:    map[uint64]*m.File{},
-		stored:   map[uint64]*m.File{},
-		sent:     map[m.Id]struct{}{},
+		paths:    m.NewPaths(),
 	}
 	go s.handleEvents()
 	return s
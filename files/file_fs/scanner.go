@@ -5,9 +5,7 @@ import (
 	m "arch/model"
 	"arch/stream"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/csv"
-	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -29,17 +27,25 @@ type scanner struct {
 	commands *stream.Stream[m.FileCommand]
 	lc       *lifecycle.Lifecycle
 	files    map[uint64]*m.File
-	stored   map[uint64]*m.File
-	sent     map[m.Id]struct{}
+	paths    *m.Paths
+	blocks   m.FileBlocks
 }
 
 func (s *scanner) Send(cmd m.FileCommand) {
 	s.commands.Push(cmd)
 }
 
+func (s *scanner) Close() {
+	s.commands.Close()
+}
+
 func (s *scanner) handleEvents() {
 	for {
-		for _, cmd := range s.commands.Pull() {
+		cmds, err := s.commands.PullContext(s.lc.Context())
+		if err != nil {
+			return
+		}
+		for _, cmd := range cmds {
 			s.handleCommand(cmd)
 		}
 	}
@@ -50,6 +56,11 @@ func (s *scanner) handleCommand(cmd m.FileCommand) {
 	defer s.lc.Done()
 
 	switch cmd := cmd.(type) {
+	case m.Batch:
+		for _, cmd := range cmd {
+			s.handleCommand(cmd)
+		}
+
 	case m.ScanArchive:
 		s.scanArchive()
 
@@ -94,12 +105,13 @@ func (s *scanner) scanArchive() {
 		modTime := meta.ModTime()
 		modTime = modTime.UTC().Round(time.Second)
 
-		file := &m.File{
+		file := s.blocks.New()
+		*file = m.File{
 			Id: m.Id{
 				Root: s.root,
 				Name: m.Name{
-					Path: m.Path(dir(path)),
-					Base: m.Base(name(path)),
+					Path: s.paths.Intern(dir(path)),
+					Base: m.Base(strings.Clone(name(path))),
 				},
 			},
 			ModTime: modTime,
@@ -122,19 +134,15 @@ func (s *scanner) scanArchive() {
 		Size: totalSize,
 	})
 
-	for ino, file := range s.files {
-		if stored, ok := s.stored[ino]; ok && stored.ModTime == file.ModTime && stored.Size == file.Size {
-			file.Hash = stored.Hash
+	files := make([]*m.File, 0, len(s.files))
+	for _, file := range s.files {
+		if file.Hash.IsZero() {
+			files = append(files, file)
+		} else {
 			s.events.Push(m.FileScanned{File: file})
-			s.sent[file.Id] = struct{}{}
 		}
 	}
 
-	files := []*m.File{}
-	for _, file := range s.files {
-		files = append(files, file)
-	}
-
 	sort.Slice(files, func(i, j int) bool {
 		iName := strings.ToLower(files[i].Id.String())
 		jName := strings.ToLower(files[j].Id.String())
@@ -142,10 +150,6 @@ func (s *scanner) scanArchive() {
 	})
 
 	for _, file := range files {
-		if _, ok := s.sent[file.Id]; ok {
-			continue
-		}
-
 		s.hashFile(file)
 
 		if s.lc.ShoudStop() {
@@ -203,9 +207,11 @@ func (s *scanner) hashFile(info *m.File) {
 			Hashed: hashed,
 		})
 	}
-	info.Hash = m.Hash(base64.RawURLEncoding.EncodeToString(hash.Sum(nil)))
+	hash.Sum(info.Hash[:0])
 }
 
+// readMeta restores the hashes of files unchanged since the last scan.
+// It reads one record at a time, reusing its memory.
 func (s *scanner) readMeta() {
 	absHashFileName := filepath.Join(s.root.String(), hashFileName)
 	hashInfoFile, err := os.Open(absHashFileName)
@@ -214,58 +220,62 @@ func (s *scanner) readMeta() {
 	}
 	defer hashInfoFile.Close()
 
-	records, err := csv.NewReader(hashInfoFile).ReadAll()
-	if err != nil || len(records) == 0 {
+	reader := csv.NewReader(hashInfoFile)
+	reader.ReuseRecord = true
+	if _, err := reader.Read(); err != nil {
 		return
 	}
-
-	for _, record := range records[1:] {
+	for {
+		record, err := reader.Read()
+		if _, ok := err.(*csv.ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return
+		}
 		if len(record) == 5 {
 			iNode, er1 := strconv.ParseUint(record[0], 10, 64)
 			size, er2 := strconv.ParseUint(record[2], 10, 64)
 			modTime, er3 := time.Parse(time.RFC3339, record[3])
 			modTime = modTime.UTC().Round(time.Second)
-			hash := record[4]
-			if hash == "" || er1 != nil || er2 != nil || er3 != nil {
+			hash, er4 := m.ParseHash(record[4])
+			if er1 != nil || er2 != nil || er3 != nil || er4 != nil {
 				continue
 			}
 
-			s.stored[iNode] = &m.File{
-				ModTime: modTime,
-				Size:    uint64(size),
-				Hash:    m.Hash(hash),
-			}
 			info, ok := s.files[iNode]
-			if hash != "" && ok && info.ModTime == modTime && info.Size == size {
-				info.Hash = m.Hash(hash)
+			if ok && info.ModTime == modTime && info.Size == size {
+				info.Hash = hash
 			}
 		}
 	}
 }
 
 func (s *scanner) storeMeta() error {
-	result := make([][]string, 1, len(s.files)+1)
-	result[0] = []string{"INode", "Name", "Size", "ModTime", "Hash"}
-
-	for iNode, file := range s.files {
-		result = append(result, []string{
-			fmt.Sprint(iNode),
-			norm.NFC.String(file.Name.String()),
-			fmt.Sprint(file.Size),
-			file.ModTime.UTC().Format(time.RFC3339Nano),
-			file.Hash.String(),
-		})
-	}
-
 	absHashFileName := filepath.Join(s.root.String(), hashFileName)
 	hashInfoFile, err := os.Create(absHashFileName)
-
 	if err != nil {
 		return err
 	}
-	err = csv.NewWriter(hashInfoFile).WriteAll(result)
-	hashInfoFile.Close()
-	return err
+	defer hashInfoFile.Close()
+
+	writer := csv.NewWriter(hashInfoFile)
+	record := []string{"INode", "Name", "Size", "ModTime", "Hash"}
+	writer.Write(record)
+	for iNode, file := range s.files {
+		hash := ""
+		if !file.Hash.IsZero() {
+			hash = file.Hash.String()
+		}
+		record[0] = strconv.FormatUint(iNode, 10)
+		record[1] = norm.NFC.String(file.Name.String())
+		record[2] = strconv.FormatUint(file.Size, 10)
+		record[3] = file.ModTime.UTC().Format(time.RFC3339Nano)
+		record[4] = hash
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
 func dir(path string) string {
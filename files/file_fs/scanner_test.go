@@ -0,0 +1,74 @@
+package file_fs
+
+import (
+	"arch/lifecycle"
+	m "arch/model"
+	"arch/stream"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+var benchFiles = flag.Int("files", 20_000, "files in the generated directory tree of benchmarks")
+
+// generateTree writes files of distinct content into folders like the generated mock archive.
+func generateTree(b *testing.B, files int) m.Root {
+	root := b.TempDir()
+	content := make([]byte, 8)
+	for i := 0; i < files; i++ {
+		path := filepath.Join(root, fmt.Sprintf("folder-%d/folder-%d/file-%d.dat", i%97, i%89, i))
+		if i < 97*89 {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				b.Fatal(err)
+			}
+		}
+		binary.LittleEndian.PutUint64(content, uint64(i))
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return m.Root(root)
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// BenchmarkScanMemory reports the heap the scanner keeps per scanned file of a generated
+// directory tree. The scanned events are dropped, so only the records of the scanner count.
+// Run it with: go test ./files/file_fs -run NONE -bench ScanMemory -benchtime 1x
+func BenchmarkScanMemory(b *testing.B) {
+	root := generateTree(b, *benchFiles)
+	for i := 0; i < b.N; i++ {
+		os.Remove(filepath.Join(root.String(), hashFileName))
+		lc := lifecycle.New()
+		events := stream.NewStream[m.Event]("bench")
+		before := heapAlloc()
+		scanner := NewFs(events, lc).NewArchiveScanner(root)
+		scanner.Send(m.ScanArchive{})
+		files := 0
+	scan:
+		for {
+			for _, event := range events.Pull() {
+				switch event.(type) {
+				case m.FileScanned:
+					files++
+				case m.ArchiveScanned:
+					break scan
+				}
+			}
+		}
+		after := heapAlloc()
+		b.ReportMetric(float64(after-before)/float64(files), "B/file")
+		runtime.KeepAlive(scanner)
+		scanner.Close()
+		lc.Stop()
+	}
+}
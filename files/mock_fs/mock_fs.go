@@ -3,33 +3,105 @@ package mock_fs
 import (
 	m "arch/model"
 	"arch/stream"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"math/rand"
+	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
-var Scan bool
+// Delay is the pause between progress events of simulated hashing and copying.
+var Delay = time.Millisecond
 
+// mockFs keeps the files of the roots in memory and applies the commands to them.
 type mockFs struct {
 	eventStream *stream.Stream[m.Event]
+	scenario    *Scenario
+	lock        sync.Mutex
+	roots       map[m.Root]map[string]*fileMeta
 }
 
 type scanner struct {
 	root        m.Root
+	fs          *mockFs
 	eventStream *stream.Stream[m.Event]
 	commands    *stream.Stream[m.FileCommand]
+	paths       *m.Paths
+	blocks      m.FileBlocks
+}
+
+type fileMeta struct {
+	Hash    m.Hash
+	Size    uint64
+	ModTime time.Time
 }
 
 func NewFs(eventStream *stream.Stream[m.Event]) m.FS {
-	fs := &mockFs{eventStream: eventStream}
-	return fs
+	return NewScenarioFs(eventStream, DefaultScenario())
+}
+
+func NewScenarioFs(eventStream *stream.Stream[m.Event], scenario *Scenario) m.FS {
+	return &mockFs{eventStream: eventStream, scenario: scenario, roots: scenario.files()}
+}
+
+// NewGeneratedFs simulates roots of the given number of files each. The roots are
+// copies of each other where a tenth of the files are missing, renamed or changed.
+func NewGeneratedFs(eventStream *stream.Stream[m.Event], roots []m.Root, files int, seed int64) m.FS {
+	rng := rand.New(rand.NewSource(seed))
+	generated := map[m.Root]map[string]*fileMeta{}
+	for _, root := range roots {
+		generated[root] = make(map[string]*fileMeta, files)
+	}
+	for i := 0; i < files; i++ {
+		fullName := fmt.Sprintf("folder-%d/folder-%d/file-%d.dat", i%97, i%89, i)
+		var hash m.Hash
+		binary.LittleEndian.PutUint64(hash[:], uint64(i))
+		size := uint64(rng.Int63n(100000000))
+		modTime := beginning.Add(time.Duration(rng.Int63n(int64(duration))))
+		for idx, root := range roots {
+			meta := &fileMeta{Hash: hash, Size: size, ModTime: modTime}
+			name := fullName
+			if idx > 0 {
+				switch rng.Intn(40) {
+				case 0:
+					continue
+				case 1:
+					name = fmt.Sprintf("folder-%d/renamed-%d.dat", i%97, i)
+				case 2:
+					meta.Hash[8] = byte(idx)
+				}
+			}
+			generated[root][name] = meta
+		}
+	}
+	return &mockFs{eventStream: eventStream, scenario: &Scenario{Seed: seed}, roots: generated}
+}
+
+// Files returns the hashes of the files currently in the roots of the simulated file system.
+func Files(fs m.FS) map[m.Id]m.Hash {
+	mock := fs.(*mockFs)
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	result := map[m.Id]m.Hash{}
+	for root, files := range mock.roots {
+		for fullName, meta := range files {
+			result[m.Id{Root: root, Name: m.Name{Path: m.Path(dir(fullName)), Base: name(fullName)}}] = meta.Hash
+		}
+	}
+	return result
 }
 
 func (fs *mockFs) NewArchiveScanner(root m.Root) m.ArchiveScanner {
 	s := &scanner{
 		root:        root,
+		fs:          fs,
 		eventStream: fs.eventStream,
 		commands:    stream.NewStream[m.FileCommand](root.String()),
+		paths:       m.NewPaths(),
 	}
 	go s.handleEvents()
 	return s
@@ -39,9 +111,13 @@ func (s *scanner) Send(cmd m.FileCommand) {
 	s.commands.Push(cmd)
 }
 
+func (s *scanner) Close() {
+	s.commands.Close()
+}
+
 func (s *scanner) handleEvents() {
-	for {
-		for _, cmd := range s.commands.Pull() {
+	for cmds := s.commands.Pull(); len(cmds) > 0; cmds = s.commands.Pull() {
+		for _, cmd := range cmds {
 			s.handleCommand(cmd)
 		}
 	}
@@ -49,39 +125,37 @@ func (s *scanner) handleEvents() {
 
 func (s *scanner) handleCommand(cmd m.FileCommand) {
 	switch cmd := cmd.(type) {
+	case m.Batch:
+		for _, cmd := range cmd {
+			s.handleCommand(cmd)
+		}
+
 	case m.ScanArchive:
 		s.scanArchive()
 
 	case m.DeleteFile:
+		if err := s.fs.delete(cmd.Id); err != nil {
+			s.eventStream.Push(m.Error{Id: cmd.Id, Error: err})
+		}
 		s.eventStream.Push(m.FileDeleted(cmd))
 
 	case m.RenameFile:
+		if err := s.fs.rename(cmd.From, cmd.To); err != nil {
+			s.eventStream.Push(m.Error{Id: cmd.From, Error: err})
+		}
 		s.eventStream.Push(m.FileRenamed(cmd))
+
 	case m.CopyFile:
-		for _, meta := range metas[cmd.From.Root] {
-			if meta.FullName == cmd.From.Name.String() {
-				for copied := uint64(0); ; copied += 50000 {
-					if copied > meta.Size {
-						copied = meta.Size
-					}
-					s.eventStream.Push(m.CopyingProgress(copied))
-					if copied == meta.Size {
-						break
-					}
-					time.Sleep(time.Millisecond)
-				}
-				break
-			}
-		}
+		s.copyFile(cmd)
 		s.eventStream.Push(m.FileCopied(cmd))
 	}
 }
 
 func (s *scanner) scanArchive() {
-	archFiles := metas[s.root]
+	names, metas := s.fs.list(s.root)
 	totalSize := uint64(0)
-	for _, file := range archFiles {
-		totalSize += file.Size
+	for _, meta := range metas {
+		totalSize += meta.Size
 	}
 
 	s.eventStream.Push(m.TotalSize{
@@ -89,174 +163,219 @@ func (s *scanner) scanArchive() {
 		Size: totalSize,
 	})
 
-	scans := make([]bool, len(archFiles))
+	scans := make([]bool, len(metas))
 
-	for i := range archFiles {
-		scans[i] = Scan && rand.Intn(2) == 0
+	rng := rand.New(rand.NewSource(s.fs.scenario.Seed + int64(crc32.ChecksumIEEE([]byte(s.root)))))
+	for i := range metas {
+		scans[i] = s.fs.scenario.Scan && rng.Intn(2) == 0
 	}
-	for i := range archFiles {
+	for i := range metas {
 		if !scans[i] {
-			meta := archFiles[i]
-			s.eventStream.Push(m.FileScanned{
-				File: &m.File{
-					Id: m.Id{
-						Root: meta.Root,
-						Name: m.Name{
-							Path: dir(meta.FullName),
-							Base: name(meta.FullName),
-						},
-					},
-					Size:    meta.Size,
-					ModTime: meta.ModTime,
-					Hash:    meta.Hash,
-				},
-			})
+			s.scanFile(names[i], metas[i], false)
 		}
 	}
-	for i := range archFiles {
+	for i := range metas {
 		if scans[i] {
-			meta := archFiles[i]
-			for hashed := uint64(0); ; hashed += 50000 {
-				if hashed > meta.Size {
-					hashed = meta.Size
-				}
-				s.eventStream.Push(m.HashingProgress{Root: meta.Root, Hashed: hashed})
-				if hashed == meta.Size {
-					break
-				}
-				time.Sleep(time.Millisecond)
-			}
-			s.eventStream.Push(m.FileScanned{
-				File: &m.File{
-					Id: m.Id{
-						Root: meta.Root,
-						Name: m.Name{
-							Path: dir(meta.FullName),
-							Base: name(meta.FullName),
-						},
-					},
-					Size:    meta.Size,
-					ModTime: meta.ModTime,
-					Hash:    meta.Hash,
-				},
-			})
+			s.scanFile(names[i], metas[i], true)
 		}
 	}
 	s.eventStream.Push(m.ArchiveScanned{Root: s.root})
 }
 
-var beginning = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
-var end = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
-var duration = end.Sub(beginning)
-
-type fileMeta struct {
-	INode uint64
-	m.Root
-	FullName string
-	m.Hash
-	Size    uint64
-	ModTime time.Time
+func (s *scanner) scanFile(fullName string, meta fileMeta, hashing bool) {
+	id := m.Id{Root: s.root, Name: m.Name{Path: s.paths.Intern(dir(fullName)), Base: name(fullName)}}
+	time.Sleep(s.fs.slowness(OpScan, id))
+	fault := s.fs.fault(OpScan, id)
+	if fault != nil && fault.Kind == Permission {
+		s.eventStream.Push(m.Error{Id: id, Error: fault.err(OpScan, id)})
+		return
+	}
+	file := s.blocks.New()
+	*file = m.File{Id: id, Size: meta.Size, ModTime: meta.ModTime, Hash: meta.Hash}
+	if fault != nil && fault.Kind == IO {
+		s.progress(OpScan, id, 0, fault.failsAt(meta.Size), func(hashed uint64) {
+			s.eventStream.Push(m.HashingProgress{Root: s.root, Hashed: hashed})
+		})
+		s.eventStream.Push(m.Error{Id: id, Error: fault.err(OpScan, id)})
+		file.Hash = m.Hash{}
+	} else if hashing {
+		s.progress(OpScan, id, 0, meta.Size, func(hashed uint64) {
+			s.eventStream.Push(m.HashingProgress{Root: s.root, Hashed: hashed})
+		})
+	}
+	s.eventStream.Push(m.FileScanned{File: file})
+	if fault != nil && fault.Kind == Vanish {
+		s.fs.remove(id)
+	}
 }
 
-var sizeByName = map[string]uint64{}
-var sizeByHash = map[m.Hash]uint64{}
-var modTimes = map[m.Hash]time.Time{}
-var inode = uint64(0)
-
-func init() {
-	sizeByHash["yyyy"] = 50000000
-	sizeByHash["hhhh"] = 50000000
-	for root, metaStrings := range metaMap {
-		for name, hash := range metaStrings {
-			size, ok := sizeByName[name]
-			if !ok {
-				size, ok = sizeByHash[hash]
-				if !ok {
-					size = uint64(rand.Intn(100000000))
-					sizeByHash[hash] = size
-				}
+// copyFile reads the source once and writes all the targets. A fault of the source
+// stops the copy, a fault of a target only stops writing that target.
+func (s *scanner) copyFile(cmd m.CopyFile) {
+	source, err := s.fs.stat(OpCopy, cmd.From)
+	if err != nil {
+		s.eventStream.Push(m.Error{Id: cmd.From, Error: err})
+		return
+	}
+	ids := append([]m.Id{cmd.From}, cmd.To...)
+	faults := make([]*Fault, len(ids))
+	for i, id := range ids {
+		if fault := s.fs.fault(OpCopy, id); fault != nil && (fault.Kind == Permission || fault.Kind == IO) {
+			faults[i] = fault
+		}
+	}
+
+	for copied := uint64(0); ; {
+		next := source.Size
+		for _, fault := range faults {
+			if fault != nil && fault.failsAt(source.Size) < next {
+				next = fault.failsAt(source.Size)
 			}
-			modTime, ok := modTimes[hash]
-			if !ok {
-				modTime = beginning.Add(time.Duration(rand.Int63n(int64(duration))))
-				modTimes[hash] = modTime
+		}
+		s.progress(OpCopy, cmd.From, copied, next, func(copied uint64) {
+			s.eventStream.Push(m.CopyingProgress(copied))
+		})
+		copied = next
+		for i, fault := range faults {
+			if fault == nil || fault.failsAt(source.Size) != copied {
+				continue
 			}
-			inode++
-			file := &fileMeta{
-				INode:    inode,
-				Root:     root,
-				FullName: name,
-				Hash:     hash,
-				Size:     size,
-				ModTime:  modTime,
+			s.eventStream.Push(m.Error{Id: ids[i], Error: fault.err(OpCopy, ids[i])})
+			if i == 0 {
+				return
 			}
-			metas[root] = append(metas[root], file)
+			faults[i], ids[i] = nil, m.Id{}
+		}
+		if copied == source.Size {
+			break
+		}
+	}
+	for _, id := range ids[1:] {
+		if id != (m.Id{}) {
+			s.fs.put(id, source)
+		}
+	}
+}
+
+// progress reports processing the bytes from..to in steps, pausing after each step.
+// Resuming from a nonzero offset does not report the offset again.
+func (s *scanner) progress(op Op, id m.Id, from, to uint64, report func(uint64)) {
+	delay := Delay + s.fs.slowness(op, id)
+	for done := from; ; done += 50000 {
+		if done > to {
+			done = to
+		}
+		if done > from || from == 0 {
+			report(done)
+		}
+		if done == to {
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+// list returns the files of the root sorted by name.
+func (fs *mockFs) list(root m.Root) ([]string, []fileMeta) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	files := fs.roots[root]
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metas := make([]fileMeta, len(names))
+	for i, name := range names {
+		metas[i] = *files[name]
+	}
+	return names, metas
+}
+
+// fault returns the first fault failing the operation on the file.
+func (fs *mockFs) fault(op Op, id m.Id) *Fault {
+	for i := range fs.scenario.Faults {
+		if fault := &fs.scenario.Faults[i]; fault.Kind != Slow && fault.matches(op, id) {
+			return fault
+		}
+	}
+	return nil
+}
+
+func (fs *mockFs) slowness(op Op, id m.Id) time.Duration {
+	delay := time.Duration(0)
+	for i := range fs.scenario.Faults {
+		if fault := &fs.scenario.Faults[i]; fault.Kind == Slow && fault.matches(op, id) {
+			delay += time.Duration(fault.Delay)
 		}
 	}
+	return delay
+}
+
+// stat returns the file, or the error the operation on it fails with right away.
+func (fs *mockFs) stat(op Op, id m.Id) (fileMeta, error) {
+	time.Sleep(fs.slowness(op, id))
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	meta, ok := fs.roots[id.Root][id.Name.String()]
+	if !ok {
+		return fileMeta{}, &os.PathError{Op: string(op), Path: id.String(), Err: os.ErrNotExist}
+	}
+	if fault := fs.fault(op, id); fault != nil && fault.Kind == Permission {
+		return fileMeta{}, fault.err(op, id)
+	}
+	return *meta, nil
+}
+
+func (fs *mockFs) delete(id m.Id) error {
+	if _, err := fs.stat(OpDelete, id); err != nil {
+		return err
+	}
+	if fault := fs.fault(OpDelete, id); fault != nil && fault.Kind == IO {
+		return fault.err(OpDelete, id)
+	}
+	fs.remove(id)
+	return nil
 }
 
-var metas = map[m.Root][]*fileMeta{}
-var metaMap = map[m.Root]map[string]m.Hash{
-	"origin": {
-		"0000":            "0000",
-		"6666":            "6666",
-		"7777":            "7777",
-		"a/b/e/f.txt":     "gggg",
-		"a/b/e/g.txt":     "tttt",
-		"x/xxx.txt":       "hhhh",
-		"q/w/e/r/t/y.txt": "qwerty",
-		"qqq.txt":         "hhhh",
-		"uuu.txt":         "hhhh",
-		"xxx.txt":         "xxxx",
-		"yyy.txt":         "yyyy",
-		"same":            "same",
-		"different":       "different",
-	},
-	"copy 1": {
-		"xxx.txt":     "xxxx",
-		"a/b/c/d.txt": "llll",
-		"a/b/e/f.txt": "hhhh",
-		"a/b/e/g.txt": "tttt",
-		"qqq.txt":     "mmmm",
-		"y.txt":       "gggg",
-		"x/xxx.txt":   "hhhh",
-		"zzz.txt":     "hhhh",
-		"x/y/z.txt":   "zzzz",
-		"yyy.txt":     "yyyy",
-		"1111":        "0000",
-		"9999":        "9999",
-		"4444":        "4444",
-		"8888":        "9999",
-		"b/bbb.txt":   "bbbb",
-		"6666":        "6666",
-		"7777":        "7777",
-		"same":        "same-copy",
-		"different":   "different-copy1",
-	},
-	"copy 2": {
-		"xxx.txt":         "xxxx",
-		"a/b/e/x.txt":     "gggg",
-		"a/b/e/g.txt":     "tttt",
-		"x":               "asdfg",
-		"q/w/e/r/t/y.txt": "12345",
-		"2222":            "0000",
-		"9999":            "9999",
-		"5555":            "4444",
-		"6666":            "7777",
-		"7777":            "6666",
-		"8888":            "8888",
-		"c/ccc.txt":       "bbbb",
-		"same":            "same-copy",
-		"different":       "different-copy2",
-	},
+func (fs *mockFs) rename(from, to m.Id) error {
+	meta, err := fs.stat(OpRename, from)
+	if err != nil {
+		return err
+	}
+	if fault := fs.fault(OpRename, from); fault != nil && fault.Kind == IO {
+		return fault.err(OpRename, from)
+	}
+	fs.remove(from)
+	fs.put(to, meta)
+	return nil
+}
+
+func (fs *mockFs) remove(id m.Id) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	delete(fs.roots[id.Root], id.Name.String())
 }
 
-func dir(path string) m.Path {
+func (fs *mockFs) put(id m.Id, meta fileMeta) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	if fs.roots[id.Root] == nil {
+		fs.roots[id.Root] = map[string]*fileMeta{}
+	}
+	fs.roots[id.Root][id.Name.String()] = &meta
+}
+
+var beginning = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+var end = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+var duration = end.Sub(beginning)
+
+func dir(path string) string {
 	path = filepath.Dir(path)
 	if path == "." {
 		return ""
 	}
-	return m.Path(path)
+	return path
 }
 
 func name(path string) m.Base {
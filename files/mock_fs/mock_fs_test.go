@@ -0,0 +1,113 @@
+package mock_fs
+
+import (
+	m "arch/model"
+	"arch/stream"
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+const faultScenario = `{
+  "seed": 7,
+  "sizes": {"big": 120000},
+  "roots": [
+    {"root": "origin", "files": {"a/big.txt": "big", "gone.txt": "gone", "locked.txt": "locked"}},
+    {"root": "copy", "files": {}},
+    {"root": "broken", "files": {}}
+  ],
+  "faults": [
+    {"op": "scan", "root": "origin", "path": "gone.txt", "kind": "vanish"},
+    {"op": "delete", "path": "locked.txt", "kind": "permission"},
+    {"op": "copy", "root": "broken", "kind": "io", "at": 60000},
+    {"op": "copy", "kind": "slow", "delay": "1ms"}
+  ]
+}`
+
+func newTestFs(t *testing.T) (*mockFs, *stream.Stream[m.Event]) {
+	Delay = 0
+	scenario, err := ParseScenario([]byte(faultScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewStream[m.Event]("test")
+	return NewScenarioFs(events, scenario).(*mockFs), events
+}
+
+// run sends the command and returns the events up to the one closing it.
+func run(events *stream.Stream[m.Event], s m.ArchiveScanner, cmd m.FileCommand, last func(m.Event) bool) []m.Event {
+	s.Send(cmd)
+	var result []m.Event
+	for {
+		for _, event := range events.Pull() {
+			result = append(result, event)
+			if last(event) {
+				return result
+			}
+		}
+	}
+}
+
+func isScanned(event m.Event) bool { _, ok := event.(m.ArchiveScanned); return ok }
+func isCopied(event m.Event) bool  { _, ok := event.(m.FileCopied); return ok }
+func isDeleted(event m.Event) bool { _, ok := event.(m.FileDeleted); return ok }
+
+func TestParseScenario(t *testing.T) {
+	if _, err := ParseScenario([]byte(`{"roots": [{"root": "a"}], "faults": [{"kind": "flaky"}]}`)); err == nil {
+		t.Error("expected an error for an unknown fault kind")
+	}
+	if _, err := ParseScenario([]byte(`{}`)); err == nil {
+		t.Error("expected an error for a scenario without roots")
+	}
+	if roots := DefaultScenario().RootNames(); !reflect.DeepEqual(roots, []m.Root{"origin", "copy 1", "copy 2"}) {
+		t.Errorf("default roots = %v", roots)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	fs, events := newTestFs(t)
+	s := fs.NewArchiveScanner("origin")
+	defer s.Close()
+
+	run(events, s, m.ScanArchive{}, isScanned)
+	if _, ok := fs.roots["origin"]["gone.txt"]; ok {
+		t.Error("vanishing file is still there after scanning")
+	}
+
+	gone := m.Id{Root: "origin", Name: m.Name{Base: "gone.txt"}}
+	result := run(events, s, m.CopyFile{From: gone, To: []m.Id{{Root: "copy", Name: gone.Name}}}, isCopied)
+	if err, ok := result[0].(m.Error); !ok || err.Id != gone || !errors.Is(err.Error, os.ErrNotExist) {
+		t.Errorf("copying vanished file: %#v", result)
+	}
+
+	locked := m.Id{Root: "origin", Name: m.Name{Base: "locked.txt"}}
+	result = run(events, s, m.DeleteFile{Id: locked}, isDeleted)
+	if err, ok := result[0].(m.Error); !ok || !errors.Is(err.Error, os.ErrPermission) {
+		t.Errorf("deleting locked file: %#v", result)
+	}
+	if _, ok := fs.roots["origin"]["locked.txt"]; !ok {
+		t.Error("locked file was deleted")
+	}
+
+	big := m.Id{Root: "origin", Name: m.Name{Path: "a", Base: "big.txt"}}
+	toCopy := m.Id{Root: "copy", Name: big.Name}
+	toBroken := m.Id{Root: "broken", Name: big.Name}
+	result = run(events, s, m.CopyFile{From: big, To: []m.Id{toCopy, toBroken}}, isCopied)
+	failed := m.Error{Id: toBroken, Error: &os.PathError{Op: "copy", Path: toBroken.String(), Err: syscall.EIO}}
+	expected := []m.Event{
+		m.CopyingProgress(0), m.CopyingProgress(50000), m.CopyingProgress(60000), failed,
+		m.CopyingProgress(110000), m.CopyingProgress(120000),
+		m.FileCopied{From: big, To: []m.Id{toCopy, toBroken}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("copying with a failing target:\ngot  %#v\nwant %#v", result, expected)
+	}
+	if _, ok := fs.roots["copy"]["a/big.txt"]; !ok {
+		t.Error("file was not copied")
+	}
+	if _, ok := fs.roots["broken"]["a/big.txt"]; ok {
+		t.Error("file was copied to the failing root")
+	}
+}
@@ -0,0 +1,189 @@
+package mock_fs
+
+import (
+	m "arch/model"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+)
+
+//go:embed default.json
+var defaultScenario []byte
+
+// Scenario describes simulated roots and the faults they show.
+type Scenario struct {
+	// Seed makes the sizes, modification times and hashing order repeatable.
+	Seed int64 `json:"seed"`
+	// Scan makes about half of the files to be hashed while scanning, reporting progress.
+	Scan  bool           `json:"scan"`
+	Roots []RootScenario `json:"roots"`
+	// Sizes maps content labels to sizes, other contents get random sizes.
+	Sizes  map[string]uint64 `json:"sizes"`
+	Faults []Fault           `json:"faults"`
+}
+
+// RootScenario maps the file names of a root to content labels.
+// Files with the same label have the same content.
+type RootScenario struct {
+	Root  m.Root            `json:"root"`
+	Files map[string]string `json:"files"`
+}
+
+type Op string
+
+const (
+	OpScan   Op = "scan"
+	OpCopy   Op = "copy"
+	OpDelete Op = "delete"
+	OpRename Op = "rename"
+)
+
+type Kind string
+
+const (
+	// Permission fails the operation right away.
+	Permission Kind = "permission"
+	// IO fails the operation after At bytes were read or written.
+	IO Kind = "io"
+	// Vanish removes the file right after it was scanned.
+	Vanish Kind = "vanish"
+	// Slow adds Delay to every step of the operation.
+	Slow Kind = "slow"
+)
+
+// Fault applies to the operations on matching files. An empty Op, Root or Path matches any.
+// For copying, Root is the root being read from or written to.
+type Fault struct {
+	Op    Op       `json:"op"`
+	Root  m.Root   `json:"root"`
+	Path  string   `json:"path"`
+	Kind  Kind     `json:"kind"`
+	At    uint64   `json:"at"`
+	Delay Duration `json:"delay"`
+}
+
+// Duration reads durations like "20ms" from JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	*d = Duration(duration)
+	return err
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (f *Fault) matches(op Op, id m.Id) bool {
+	return (f.Op == "" || f.Op == op) &&
+		(f.Root == "" || f.Root == id.Root) &&
+		(f.Path == "" || f.Path == id.Name.String())
+}
+
+func (f *Fault) err(op Op, id m.Id) error {
+	switch f.Kind {
+	case Permission:
+		return &os.PathError{Op: string(op), Path: id.String(), Err: os.ErrPermission}
+	case IO:
+		return &os.PathError{Op: string(op), Path: id.String(), Err: syscall.EIO}
+	}
+	return nil
+}
+
+// failsAt tells how many bytes of the file are processed before the fault.
+func (f *Fault) failsAt(size uint64) uint64 {
+	if f.Kind != IO {
+		return 0
+	}
+	if f.At < size {
+		return f.At
+	}
+	return size
+}
+
+func DefaultScenario() *Scenario {
+	scenario, err := ParseScenario(defaultScenario)
+	if err != nil {
+		panic(err)
+	}
+	return scenario
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+func ParseScenario(data []byte) (*Scenario, error) {
+	scenario := &Scenario{}
+	if err := json.Unmarshal(data, scenario); err != nil {
+		return nil, err
+	}
+	if len(scenario.Roots) == 0 {
+		return nil, fmt.Errorf("scenario has no roots")
+	}
+	for _, fault := range scenario.Faults {
+		switch fault.Kind {
+		case Permission, IO, Vanish, Slow:
+		default:
+			return nil, fmt.Errorf("unknown fault kind %q", fault.Kind)
+		}
+	}
+	return scenario, nil
+}
+
+func (s *Scenario) RootNames() []m.Root {
+	roots := make([]m.Root, len(s.Roots))
+	for i, root := range s.Roots {
+		roots[i] = root.Root
+	}
+	return roots
+}
+
+// files makes the files of the roots. Sizes and times are drawn in the order of
+// root and file names, so they do not depend on the order in the scenario file.
+func (s *Scenario) files() map[m.Root]map[string]*fileMeta {
+	rng := rand.New(rand.NewSource(s.Seed))
+	sizes, modTimes := map[string]uint64{}, map[string]time.Time{}
+	for label, size := range s.Sizes {
+		sizes[label] = size
+	}
+	roots := append([]RootScenario{}, s.Roots...)
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Root < roots[j].Root })
+
+	result := map[m.Root]map[string]*fileMeta{}
+	for _, root := range roots {
+		files := map[string]*fileMeta{}
+		names := make([]string, 0, len(root.Files))
+		for name := range root.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			label := root.Files[name]
+			size, ok := sizes[label]
+			if !ok {
+				size = uint64(rng.Intn(100000000))
+				sizes[label] = size
+			}
+			modTime, ok := modTimes[label]
+			if !ok {
+				modTime = beginning.Add(time.Duration(rng.Int63n(int64(duration))))
+				modTimes[label] = modTime
+			}
+			files[name] = &fileMeta{Hash: sha256.Sum256([]byte(label)), Size: size, ModTime: modTime}
+		}
+		result[root.Root] = files
+	}
+	return result
+}
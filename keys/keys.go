@@ -0,0 +1,242 @@
+package keys
+
+import (
+	m "arch/model"
+	"fmt"
+	"strings"
+)
+
+type Action struct {
+	Name  string
+	Help  string
+	Event m.Event
+	Keys  []string
+}
+
+var Actions = []Action{
+	{Name: "quit", Help: "Quit", Event: m.Quit{}, Keys: []string{"Ctrl+C"}},
+	{Name: "open", Help: "Open selected file", Event: m.Open{}, Keys: []string{"Enter"}},
+	{Name: "reveal", Help: "Reveal selected file", Event: m.Reveal{}, Keys: []string{"Ctrl+R"}},
+	{Name: "select-first", Help: "Select first entry", Event: m.SelectFirst{}, Keys: []string{"Home", "g g"}},
+	{Name: "select-last", Help: "Select last entry", Event: m.SelectLast{}, Keys: []string{"End", "G"}},
+	{Name: "page-up", Help: "Page up", Event: m.PgUp{}, Keys: []string{"PgUp"}},
+	{Name: "page-down", Help: "Page down", Event: m.PgDn{}, Keys: []string{"PgDn"}},
+	{Name: "move-up", Help: "Select previous entry", Event: m.MoveSelection{Lines: -1}, Keys: []string{"Up"}},
+	{Name: "move-down", Help: "Select next entry", Event: m.MoveSelection{Lines: 1}, Keys: []string{"Down"}},
+	{Name: "exit-folder", Help: "Go to parent folder", Event: m.Exit{}, Keys: []string{"Left"}},
+	{Name: "enter-folder", Help: "Enter selected folder", Event: m.Enter{}, Keys: []string{"Right"}},
+	{Name: "mark", Help: "Mark or unmark selected entry", Event: m.ToggleMark{}, Keys: []string{"Space"}},
+	{Name: "mark-up", Help: "Extend marks up", Event: m.ExtendMark{Lines: -1}, Keys: []string{"Shift+Up"}},
+	{Name: "mark-down", Help: "Extend marks down", Event: m.ExtendMark{Lines: 1}, Keys: []string{"Shift+Down"}},
+	{Name: "mark-state", Help: "Mark all entries in the selected state", Event: m.MarkByState{}, Keys: []string{"*"}},
+	{Name: "unmark-all", Help: "Clear all marks", Event: m.ClearMarks{}, Keys: []string{"u"}},
+	{Name: "keep-one", Help: "Keep selected or marked files", Event: m.KeepOne{}, Keys: []string{"Ctrl+K", "k"}},
+	{Name: "keep-all", Help: "Keep all inconsistent files in folder", Event: m.KeepAll{}, Keys: []string{"Ctrl+A"}},
+	{Name: "cancel", Help: "Cancel pending operation", Event: m.Cancel{}, Keys: []string{"Esc"}},
+	{Name: "next-duplicate", Help: "Go to next duplicate", Event: m.Tab{}, Keys: []string{"Tab"}},
+	{Name: "delete", Help: "Delete selected or marked files", Event: m.Delete{}, Keys: []string{"Ctrl+Delete", "Alt+Backspace"}},
+	{Name: "details", Help: "Toggle detail pane", Event: m.ToggleDetails{}, Keys: []string{"i"}},
+	{Name: "presence", Help: "Toggle per-root presence columns", Event: m.TogglePresence{}, Keys: []string{"c"}},
+	{Name: "sort-next", Help: "Sort by next column", Event: m.SortNext{}, Keys: []string{"s"}},
+	{Name: "sort-reverse", Help: "Reverse sort order", Event: m.SortReverse{}, Keys: []string{"S"}},
+	{Name: "problems", Help: "Toggle problems list", Event: m.ToggleProblems{}, Keys: []string{"p"}},
+	{Name: "filter", Help: "Cycle state filter", Event: m.CycleFilter{}, Keys: []string{"f"}},
+	{Name: "search", Help: "Filter current folder", Event: m.Search{}, Keys: []string{"/"}},
+	{Name: "search-all", Help: "Search all folders", Event: m.Search{Recursive: true}, Keys: []string{"Ctrl+F"}},
+	{Name: "help", Help: "Show this help", Event: m.ToggleHelp{}, Keys: []string{"?", "F1"}},
+	{Name: "debug", Help: "Log view state", Event: m.Debug{}, Keys: []string{"F12"}},
+}
+
+// DialogActions are bound while a dialog or a pane covers the screen.
+var DialogActions = []Action{
+	{Name: "dialog-previous", Help: "Focus previous button", Event: m.DialogFocus{Delta: -1}, Keys: []string{"Left", "Backtab"}},
+	{Name: "dialog-next", Help: "Focus next button", Event: m.DialogFocus{Delta: 1}, Keys: []string{"Right", "Tab"}},
+	{Name: "dialog-up", Help: "Scroll up", Event: m.DialogScroll{Lines: -1}, Keys: []string{"Up"}},
+	{Name: "dialog-down", Help: "Scroll down", Event: m.DialogScroll{Lines: 1}, Keys: []string{"Down"}},
+	{Name: "dialog-page-up", Help: "Scroll page up", Event: m.DialogScroll{Lines: -10}, Keys: []string{"PgUp"}},
+	{Name: "dialog-page-down", Help: "Scroll page down", Event: m.DialogScroll{Lines: 10}, Keys: []string{"PgDn"}},
+	{Name: "dialog-select", Help: "Press focused button", Event: m.DialogSelect{}, Keys: []string{"Enter", "Space"}},
+	{Name: "dialog-cancel", Help: "Cancel dialog or close pane", Event: m.Cancel{}, Keys: []string{"Esc"}},
+}
+
+// MouseActions are the gestures the renderers turn into events.
+var MouseActions = []Action{
+	{Name: "click", Help: "Select entry, copy or folder in the breadcrumbs", Event: m.MouseTarget{}, Keys: []string{"Click"}},
+	{Name: "double-click", Help: "Open selected entry", Event: m.Open{}, Keys: []string{"Double-click"}},
+	{Name: "drag", Help: "Mark a range of entries", Event: m.MouseDrag{}, Keys: []string{"Drag"}},
+	{Name: "click-header", Help: "Sort by column, click again to reverse", Event: m.MouseTarget{}, Keys: []string{"Click header"}},
+	{Name: "wheel", Help: "Scroll", Event: m.Scroll{}, Keys: []string{"Wheel"}},
+}
+
+// Section is a table of actions with their keys, shown as one part of the help.
+type Section struct {
+	Title   string
+	Actions []Action
+}
+
+type Registry struct {
+	ordered  []Action
+	actions  map[string]Action
+	bindings map[string]string
+	keys     map[string][]string
+	prefixes map[string]struct{}
+	pending  []string
+	dialog   map[string]m.Event
+}
+
+// New binds the built-in actions and any extra actions, such as user commands,
+// applying the key overrides from the config.
+func New(overrides map[string][]string, extra ...Action) (*Registry, error) {
+	r := &Registry{
+		ordered:  append(append([]Action{}, Actions...), extra...),
+		actions:  map[string]Action{},
+		bindings: map[string]string{},
+		keys:     map[string][]string{},
+		prefixes: map[string]struct{}{},
+		dialog:   map[string]m.Event{},
+	}
+	for _, action := range DialogActions {
+		for _, key := range action.Keys {
+			r.dialog[key] = action.Event
+		}
+	}
+	for _, action := range r.ordered {
+		if _, ok := r.actions[action.Name]; ok {
+			return nil, fmt.Errorf("duplicate action %q", action.Name)
+		}
+		r.actions[action.Name] = action
+		r.keys[action.Name] = action.Keys
+	}
+	for name, keys := range overrides {
+		if _, ok := r.actions[name]; !ok {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+		r.keys[name] = keys
+	}
+	for _, action := range r.ordered {
+		for _, seq := range r.keys[action.Name] {
+			if err := r.bind(normalize(seq), action.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *Registry) bind(seq, name string) error {
+	if seq == "" {
+		return fmt.Errorf("empty key binding for action %q", name)
+	}
+	if other, ok := r.bindings[seq]; ok {
+		return fmt.Errorf("key %q is bound to both %q and %q", seq, other, name)
+	}
+	if _, ok := r.prefixes[seq]; ok {
+		return fmt.Errorf("key %q of action %q is a prefix of another binding", seq, name)
+	}
+	keys := strings.Split(seq, " ")
+	for i := 1; i < len(keys); i++ {
+		prefix := strings.Join(keys[:i], " ")
+		if other, ok := r.bindings[prefix]; ok {
+			return fmt.Errorf("key %q of action %q starts with %q bound to %q", seq, name, prefix, other)
+		}
+		r.prefixes[prefix] = struct{}{}
+	}
+	r.bindings[seq] = name
+	return nil
+}
+
+func (r *Registry) Resolve(key string) (m.Event, bool) {
+	seq := strings.Join(append(r.pending, key), " ")
+	if name, ok := r.bindings[seq]; ok {
+		r.pending = nil
+		return r.actions[name].Event, true
+	}
+	if _, ok := r.prefixes[seq]; ok {
+		r.pending = append(r.pending, key)
+		return nil, false
+	}
+	if len(r.pending) > 0 {
+		r.pending = nil
+		return r.Resolve(key)
+	}
+	return nil, false
+}
+
+func (r *Registry) Actions() []Action {
+	return r.ordered
+}
+
+func (r *Registry) Keys(action string) []string {
+	return r.keys[action]
+}
+
+// Sections lists the bound actions with their current keys, then the dialog keys
+// and the mouse gestures.
+func (r *Registry) Sections() []Section {
+	bound := make([]Action, len(r.ordered))
+	for i, action := range r.ordered {
+		action.Keys = r.keys[action.Name]
+		bound[i] = action
+	}
+	return []Section{
+		{Title: "Keys", Actions: bound},
+		{Title: "Dialogs", Actions: DialogActions},
+		{Title: "Mouse", Actions: MouseActions},
+	}
+}
+
+func normalize(seq string) string {
+	return strings.Join(strings.Fields(seq), " ")
+}
+
+// lookup returns the event of a single key binding, leaving any pending sequence alone.
+func (r *Registry) lookup(key string) (m.Event, bool) {
+	name, ok := r.bindings[key]
+	if !ok {
+		return nil, false
+	}
+	return r.actions[name].Event, true
+}
+
+// Route maps a key to an event depending on what the screen shows: a modal
+// overlay only takes dialog keys, quit and help; a focused text input takes
+// printable keys before they are looked up in the bindings. Keys taken by an overlay
+// or a text input drop a half-typed sequence, so that it cannot fire afterwards.
+func (r *Registry) Route(key string, modal, textInput bool) (m.Event, bool) {
+	if modal {
+		r.pending = nil
+		if event, ok := r.dialog[key]; ok {
+			return event, true
+		}
+		if event, ok := r.lookup(key); ok && (event == m.Quit{} || event == m.ToggleHelp{}) {
+			return event, true
+		}
+		return nil, false
+	}
+	if textInput {
+		if event, ok := TextInputEvent(key); ok {
+			r.pending = nil
+			return event, true
+		}
+	}
+	return r.Resolve(key)
+}
+
+func TextInputEvent(key string) (m.Event, bool) {
+	switch key {
+	case "Space":
+		return m.TextInput{Rune: ' '}, true
+	case "Backspace":
+		return m.TextBackspace{}, true
+	case "Enter":
+		return m.TextEnter{}, true
+	case "Esc":
+		return m.TextCancel{}, true
+	case "Tab":
+		return m.TextTab{}, true
+	}
+	if runes := []rune(key); len(runes) == 1 {
+		return m.TextInput{Rune: runes[0]}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package keys
+
+import (
+	m "arch/model"
+	"testing"
+)
+
+func TestSequence(t *testing.T) {
+	r, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Resolve("g"); ok {
+		t.Error("Expected pending sequence")
+	}
+	if event, ok := r.Resolve("g"); !ok || event != (m.SelectFirst{}) {
+		t.Error("Expected SelectFirst, got", event)
+	}
+	r.Resolve("g")
+	if event, ok := r.Resolve("Down"); !ok || event != (m.MoveSelection{Lines: 1}) {
+		t.Error("Expected MoveSelection, got", event)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	if _, err := New(map[string][]string{"quit": {"Ctrl+K"}}); err == nil {
+		t.Error("Expected duplicate binding error")
+	}
+	if _, err := New(map[string][]string{"quit": {"g"}}); err == nil {
+		t.Error("Expected prefix conflict error")
+	}
+	if _, err := New(map[string][]string{"quit": {"Ctrl+K x"}}); err == nil {
+		t.Error("Expected prefix conflict error")
+	}
+	if _, err := New(nil, Action{Name: "quit", Event: m.Quit{}, Keys: []string{"q"}}); err == nil {
+		t.Error("Expected duplicate action error")
+	}
+	if _, err := New(map[string][]string{"no-such-action": {"x"}}); err == nil {
+		t.Error("Expected unknown action error")
+	}
+	if _, err := New(map[string][]string{"keep-one": {"Ctrl+X k"}, "quit": {"q"}}); err != nil {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestSections(t *testing.T) {
+	r, err := New(map[string][]string{"quit": {"q"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := r.Sections()
+	if quit := sections[0].Actions[0]; quit.Name != "quit" || len(quit.Keys) != 1 || quit.Keys[0] != "q" {
+		t.Errorf("Expected quit bound to q, got %v", quit)
+	}
+	for _, action := range sections[1].Actions {
+		for _, key := range action.Keys {
+			if event, ok := r.Route(key, true, false); !ok || event != action.Event {
+				t.Errorf("Dialog key %q routes to %v, want %v", key, event, action.Event)
+			}
+		}
+	}
+}
+
+func TestModalDropsPendingSequence(t *testing.T) {
+	r, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Route("g", false, false)
+	r.Route("Esc", true, false)
+	if event, ok := r.Route("g", false, false); ok {
+		t.Error("Expected the sequence typed around the dialog not to fire, got", event)
+	}
+	r.Route("g", true, false)
+	if event, ok := r.Route("Down", false, false); !ok || event != (m.MoveSelection{Lines: 1}) {
+		t.Error("Expected MoveSelection, got", event)
+	}
+}
@@ -33,6 +33,11 @@ func (lc *Lifecycle) ShoudStop() bool {
 	}
 }
 
+// Context is done once Stop is called.
+func (lc *Lifecycle) Context() context.Context {
+	return lc.ctx
+}
+
 func (lc *Lifecycle) Stop() {
 	lc.cancel()
 	lc.wg.Wait()
@@ -3,3 +3,9 @@ package model
 type SelectFile Id
 
 type SelectFolder Path
+
+type SelectCopy Id
+
+type ScrollDetails struct{}
+
+type DialogButton int
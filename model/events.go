@@ -103,9 +103,16 @@ type Exit struct{}
 
 func (Exit) event() {}
 
-type RevealInFinder struct{}
+type Reveal struct{}
 
-func (RevealInFinder) event() {}
+func (Reveal) event() {}
+
+type OpenFailed struct {
+	Path string
+	Err  error
+}
+
+func (OpenFailed) event() {}
 
 type SelectFirst struct{}
 
@@ -127,6 +134,18 @@ type KeepAll struct{}
 
 func (KeepAll) event() {}
 
+type DialogFocus struct{ Delta int }
+
+func (DialogFocus) event() {}
+
+type DialogSelect struct{}
+
+func (DialogSelect) event() {}
+
+type Cancel struct{}
+
+func (Cancel) event() {}
+
 type Tab struct{}
 
 func (Tab) event() {}
@@ -162,6 +181,97 @@ type PgDn struct{}
 
 func (PgDn) event() {}
 
+type ToggleMark struct{}
+
+func (ToggleMark) event() {}
+
+type ExtendMark struct{ Lines int }
+
+func (ExtendMark) event() {}
+
+type MarkByState struct{}
+
+func (MarkByState) event() {}
+
+type ClearMarks struct{}
+
+func (ClearMarks) event() {}
+
+type MouseDrag struct{ Command any }
+
+func (MouseDrag) event() {}
+
+type ToggleDetails struct{}
+
+func (ToggleDetails) event() {}
+
+type TogglePresence struct{}
+
+func (TogglePresence) event() {}
+
+type SortNext struct{}
+
+func (SortNext) event() {}
+
+type SortReverse struct{}
+
+func (SortReverse) event() {}
+
+type ToggleProblems struct{}
+
+func (ToggleProblems) event() {}
+
+type CycleFilter struct{}
+
+func (CycleFilter) event() {}
+
+type Search struct{ Recursive bool }
+
+func (Search) event() {}
+
+type TextInput struct{ Rune rune }
+
+func (TextInput) event() {}
+
+type TextBackspace struct{}
+
+func (TextBackspace) event() {}
+
+type TextEnter struct{}
+
+func (TextEnter) event() {}
+
+type TextCancel struct{}
+
+func (TextCancel) event() {}
+
+type TextTab struct{}
+
+func (TextTab) event() {}
+
+type ToggleHelp struct{}
+
+func (ToggleHelp) event() {}
+
+type DialogScroll struct {
+	Lines int
+}
+
+func (DialogScroll) event() {}
+
+type RunCommand struct {
+	Name string
+}
+
+func (RunCommand) event() {}
+
+type CommandOutput struct {
+	Title string
+	Lines []string
+}
+
+func (CommandOutput) event() {}
+
 type Debug struct{}
 
 func (Debug) event() {}
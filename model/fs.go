@@ -10,6 +10,8 @@ type FS interface {
 
 type ArchiveScanner interface {
 	Send(cmd FileCommand)
+	// Close stops the scanner after it has handled the commands already sent.
+	Close()
 }
 
 type FileCommand interface {
@@ -20,6 +22,10 @@ type ScanArchive struct{}
 
 func (ScanArchive) cmd() {}
 
+type Batch []FileCommand
+
+func (Batch) cmd() {}
+
 type DeleteFile struct {
 	Hash Hash
 	Id   Id
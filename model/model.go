@@ -1,8 +1,11 @@
 package model
 
 import (
+	"encoding/base64"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -42,20 +45,82 @@ func (id Id) String() string {
 	return filepath.Join(id.Root.String(), id.Path.String(), id.Base.String())
 }
 
-type Hash string
+// Hash is the SHA-256 of the file content, the zero hash means not yet hashed.
+type Hash [32]byte
+
+func ParseHash(text string) (Hash, error) {
+	var hash Hash
+	err := hash.UnmarshalText([]byte(text))
+	return hash, err
+}
 
 func (hash Hash) String() string {
-	return string(hash)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (hash Hash) IsZero() bool {
+	return hash == Hash{}
+}
+
+func (hash Hash) MarshalText() ([]byte, error) {
+	return []byte(hash.String()), nil
+}
+
+func (hash *Hash) UnmarshalText(text []byte) error {
+	if base64.RawURLEncoding.DecodedLen(len(text)) != len(hash) {
+		return fmt.Errorf("invalid hash %q", text)
+	}
+	_, err := base64.RawURLEncoding.Decode(hash[:], text)
+	return err
 }
 
 type File struct {
 	Id
 	Size    uint64
 	ModTime time.Time
-	Hash
+	Hash    Hash
 }
 
 func (m *File) String() string {
 	return fmt.Sprintf("Meta{Root: %q, Path: %q Name: %q, Size: %d, ModTime: %s, Hash: %q}",
 		m.Root, m.Path, m.Base, m.Size, m.ModTime.Format(time.DateTime), m.Hash)
 }
+
+// Paths interns folder paths as strings, so that all files of a folder share one copy
+// of its path. The folders themselves are nodes of the folder tree of the controller.
+type Paths struct {
+	lock  sync.Mutex
+	paths map[string]Path
+}
+
+func NewPaths() *Paths {
+	return &Paths{paths: map[string]Path{}}
+}
+
+func (p *Paths) Intern(path string) Path {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	interned, ok := p.paths[path]
+	if !ok {
+		interned = Path(strings.Clone(path))
+		p.paths[path] = interned
+	}
+	return interned
+}
+
+const fileBlockSize = 4096
+
+// FileBlocks allocates files in blocks, avoiding the overhead of millions of small
+// allocations. Files are never freed or reused one by one: a block stays in memory
+// as long as any of its files is referenced, even after the others were deleted.
+type FileBlocks struct {
+	block []File
+}
+
+func (f *FileBlocks) New() *File {
+	if len(f.block) == cap(f.block) {
+		f.block = make([]File, 0, fileBlockSize)
+	}
+	f.block = f.block[:len(f.block)+1]
+	return &f.block[len(f.block)-1]
+}
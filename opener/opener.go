@@ -0,0 +1,127 @@
+package opener
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// failTimeout is how long a launched program is watched for failing right away.
+// Programs still running by then are left running, like an application which was opened.
+const failTimeout = 500 * time.Millisecond
+
+// Opener launches the desktop application associated with a file.
+// Commands are argument lists where "{path}" is replaced with the file path;
+// if no argument contains "{path}" the path is appended.
+type Opener struct {
+	open   []string
+	reveal []string
+	// revealDir is set when the reveal command opens the containing folder
+	// instead of selecting the file.
+	revealDir bool
+}
+
+var ErrNoOpener = errors.New("no command to open files found, set \"open\" in config")
+
+func New(open, reveal []string) *Opener {
+	o := &Opener{open: open, reveal: reveal}
+	if len(o.open) == 0 {
+		o.open = detect()
+	}
+	if len(o.reveal) == 0 {
+		switch runtime.GOOS {
+		case "darwin":
+			o.reveal = []string{"open", "-R", "{path}"}
+		case "windows":
+			o.reveal = []string{"explorer", "/select,{path}"}
+		default:
+			o.reveal, o.revealDir = o.open, true
+		}
+	}
+	return o
+}
+
+func detect() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open", "{path}"}
+	case "windows":
+		return []string{"cmd", "/c", "start", "", "{path}"}
+	}
+	if _, err := exec.LookPath("xdg-open"); err == nil {
+		return []string{"xdg-open", "{path}"}
+	}
+	if _, err := exec.LookPath("gio"); err == nil {
+		return []string{"gio", "open", "{path}"}
+	}
+	return nil
+}
+
+func (o *Opener) Open(path string) error {
+	return run(o.open, path)
+}
+
+// Reveal shows the file in the file manager, or opens its containing folder
+// where the platform has no way to select a file.
+func (o *Opener) Reveal(path string) error {
+	if o.revealDir {
+		path = filepath.Dir(path)
+	}
+	return run(o.reveal, path)
+}
+
+// run starts the command without waiting for the program to exit. Only failing to start
+// and failing within failTimeout are errors. The exit status of explorer is ignored,
+// since it exits with 1 even when it succeeds.
+func run(command []string, path string) error {
+	if len(command) == 0 {
+		return ErrNoOpener
+	}
+	args := expand(command, path)
+	output := &bytes.Buffer{}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(failTimeout):
+		return nil
+	}
+	if err == nil || isExplorer(args[0]) {
+		return nil
+	}
+	if msg := strings.TrimSpace(output.String()); msg != "" {
+		return fmt.Errorf("%s: %w: %s", args[0], err, msg)
+	}
+	return fmt.Errorf("%s: %w", args[0], err)
+}
+
+func isExplorer(program string) bool {
+	base := strings.ToLower(program[strings.LastIndexAny(program, `/\`)+1:])
+	return strings.TrimSuffix(base, ".exe") == "explorer"
+}
+
+func expand(command []string, path string) []string {
+	args := make([]string, len(command))
+	found := false
+	for i, arg := range command {
+		if strings.Contains(arg, "{path}") {
+			found = true
+		}
+		args[i] = strings.ReplaceAll(arg, "{path}", path)
+	}
+	if !found {
+		args = append(args, path)
+	}
+	return args
+}
@@ -0,0 +1,58 @@
+package opener
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		command []string
+		want    []string
+	}{
+		{[]string{"xdg-open", "{path}"}, []string{"xdg-open", "/a b/c"}},
+		{[]string{"explorer", "/select,{path}"}, []string{"explorer", "/select,/a b/c"}},
+		{[]string{"my-viewer", "--new"}, []string{"my-viewer", "--new", "/a b/c"}},
+	}
+	for _, test := range tests {
+		if got := expand(test.command, "/a b/c"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expand(%q): expected %q, got %q", test.command, test.want, got)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if err := run(nil, "x"); !errors.Is(err, ErrNoOpener) {
+		t.Error("Expected ErrNoOpener, got", err)
+	}
+	if err := run([]string{"arch-no-such-opener"}, "x"); err == nil {
+		t.Error("Expected error for missing command")
+	}
+}
+
+func TestRunDoesNotWait(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	start := time.Now()
+	if err := run([]string{"sh", "-c", "sleep 5"}, "x"); err != nil {
+		t.Error("Expected no error for a program still running, got", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("Expected run to return without waiting for the program")
+	}
+	if err := run([]string{"sh", "-c", "echo failed; exit 3"}, "x"); err == nil {
+		t.Error("Expected error for a program failing right away")
+	}
+}
+
+func TestIsExplorer(t *testing.T) {
+	for program, want := range map[string]bool{"explorer": true, `C:\Windows\Explorer.EXE`: true, "xdg-open": false} {
+		if got := isExplorer(program); got != want {
+			t.Errorf("isExplorer(%q) = %v, want %v", program, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package recorder
+
+import (
+	m "arch/model"
+	w "arch/widgets"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type record struct {
+	Time  time.Time       `json:"time"`
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event,omitempty"`
+}
+
+var eventTypes = typeMap(
+	m.TotalSize{}, m.FileScanned{}, m.ArchiveScanned{}, m.FileDeleted{}, m.FileRenamed{}, m.FileCopied{},
+	m.HashingProgress{}, m.CopyingProgress(0), m.ScreenSize{}, m.Open{}, m.Enter{}, m.Exit{}, m.Reveal{},
+	m.SelectFirst{}, m.SelectLast{}, m.MoveSelection{}, m.KeepOne{}, m.KeepAll{}, m.DialogFocus{},
+	m.DialogSelect{}, m.Cancel{}, m.Tab{}, m.Delete{}, m.PgUp{}, m.PgDn{}, m.ToggleMark{}, m.ExtendMark{},
+	m.MarkByState{}, m.ClearMarks{}, m.ToggleDetails{}, m.TogglePresence{}, m.SortNext{}, m.SortReverse{},
+	m.ToggleProblems{}, m.CycleFilter{}, m.Search{}, m.TextInput{}, m.TextBackspace{}, m.TextEnter{},
+	m.TextCancel{}, m.TextTab{}, m.ToggleHelp{}, m.DialogScroll{}, m.RunCommand{}, m.CommandOutput{},
+	m.Debug{}, m.Quit{},
+)
+
+var commandTypes = typeMap(
+	m.SelectFile{}, m.SelectFolder(""), m.SelectCopy{}, m.ScrollDetails{}, m.DialogButton(0), w.SortColumn(0),
+)
+
+func typeMap(values ...any) map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	for _, value := range values {
+		t := reflect.TypeOf(value)
+		types[t.Name()] = t
+	}
+	return types
+}
+
+// Events that hold errors, times or interface values are stored as these
+// structs, since encoding/json cannot read them back as they are.
+type tick struct {
+	Time time.Time
+}
+
+type errorEvent struct {
+	Id    m.Id
+	Error string
+}
+
+type openFailed struct {
+	Path string
+	Err  string
+}
+
+type command struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type commandEvent struct {
+	Command *command `json:",omitempty"`
+	Lines   int      `json:",omitempty"`
+}
+
+func encode(event m.Event) (string, []byte, error) {
+	var payload any = event
+	switch event := event.(type) {
+	case m.Tick:
+		payload = tick{Time: time.Time(event)}
+	case m.Error:
+		payload = errorEvent{Id: event.Id, Error: errorText(event.Error)}
+	case m.OpenFailed:
+		payload = openFailed{Path: event.Path, Err: errorText(event.Err)}
+	case m.MouseTarget:
+		cmd, err := encodeCommand(event.Command)
+		if err != nil {
+			return "", nil, err
+		}
+		payload = commandEvent{Command: cmd}
+	case m.MouseDrag:
+		cmd, err := encodeCommand(event.Command)
+		if err != nil {
+			return "", nil, err
+		}
+		payload = commandEvent{Command: cmd}
+	case m.Scroll:
+		cmd, err := encodeCommand(event.Command)
+		if err != nil {
+			return "", nil, err
+		}
+		payload = commandEvent{Command: cmd, Lines: event.Lines}
+	default:
+		if _, ok := eventTypes[reflect.TypeOf(event).Name()]; !ok {
+			return "", nil, fmt.Errorf("cannot record event %T", event)
+		}
+	}
+	data, err := json.Marshal(payload)
+	return reflect.TypeOf(event).Name(), data, err
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func encodeCommand(cmd any) (*command, error) {
+	if cmd == nil {
+		return nil, nil
+	}
+	name := reflect.TypeOf(cmd).Name()
+	if _, ok := commandTypes[name]; !ok {
+		return nil, fmt.Errorf("cannot record command %T", cmd)
+	}
+	data, err := json.Marshal(cmd)
+	return &command{Type: name, Value: data}, err
+}
+
+func decode(name string, data []byte) (m.Event, error) {
+	switch name {
+	case "Tick":
+		var event tick
+		err := json.Unmarshal(data, &event)
+		return m.Tick(event.Time), err
+	case "Error":
+		var event errorEvent
+		err := json.Unmarshal(data, &event)
+		return m.Error{Id: event.Id, Error: errors.New(event.Error)}, err
+	case "OpenFailed":
+		var event openFailed
+		err := json.Unmarshal(data, &event)
+		return m.OpenFailed{Path: event.Path, Err: errors.New(event.Err)}, err
+	case "MouseTarget", "MouseDrag", "Scroll":
+		var event commandEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		cmd, err := decodeCommand(event.Command)
+		switch name {
+		case "MouseTarget":
+			return m.MouseTarget{Command: cmd}, err
+		case "MouseDrag":
+			return m.MouseDrag{Command: cmd}, err
+		}
+		return m.Scroll{Command: cmd, Lines: event.Lines}, err
+	}
+	t, ok := eventTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", name)
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return nil, err
+	}
+	return value.Elem().Interface().(m.Event), nil
+}
+
+func decodeCommand(cmd *command) (any, error) {
+	if cmd == nil {
+		return nil, nil
+	}
+	t, ok := commandTypes[cmd.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown command type %q", cmd.Type)
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal(cmd.Value, value.Interface()); err != nil {
+		return nil, err
+	}
+	return value.Elem().Interface(), nil
+}
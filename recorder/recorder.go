@@ -0,0 +1,100 @@
+package recorder
+
+import (
+	m "arch/model"
+	"arch/stream"
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+type header struct {
+	Roots []m.Root `json:"roots"`
+}
+
+// Recorder writes events to a JSONL file: a header line with the archive roots
+// followed by one line per event with the time it was pushed.
+type Recorder struct {
+	file    *os.File
+	writer  *bufio.Writer
+	entries *stream.Stream[entry]
+	done    chan error
+}
+
+type entry struct {
+	time  time.Time
+	event m.Event
+}
+
+func New(path string, roots []m.Root) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		entries: stream.NewStream[entry]("recorder"),
+		done:    make(chan error, 1),
+	}
+	if err := r.writeLine(header{Roots: roots}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	go r.write()
+	return r, nil
+}
+
+// Record is meant to be used as a stream tap, so it is called with the
+// stream locked and events arrive in the order the controller sees them.
+// It only queues the event, the file is written by its own goroutine.
+func (r *Recorder) Record(event m.Event) {
+	r.entries.Push(entry{time: time.Now(), event: event})
+}
+
+func (r *Recorder) write() {
+	var err error
+	for {
+		entries := r.entries.Pull()
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			name, data, encodeErr := encode(entry.event)
+			if encodeErr != nil {
+				log.Printf("### recorder: %v", encodeErr)
+				continue
+			}
+			if writeErr := r.writeLine(record{Time: entry.time, Type: name, Event: data}); writeErr != nil && err == nil {
+				log.Printf("### recorder: %v", writeErr)
+				err = writeErr
+			}
+		}
+	}
+	r.done <- err
+}
+
+func (r *Recorder) writeLine(value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	r.writer.Write(data)
+	return r.writer.WriteByte('\n')
+}
+
+// Close writes the queued events and closes the file. Events recorded after Close are ignored.
+func (r *Recorder) Close() error {
+	r.entries.Close()
+	if err := <-r.done; err != nil {
+		r.file.Close()
+		return err
+	}
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
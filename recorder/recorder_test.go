@@ -0,0 +1,67 @@
+package recorder
+
+import (
+	m "arch/model"
+	"arch/stream"
+	w "arch/widgets"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	id := m.Id{Root: "origin", Name: m.Name{Path: "a/b", Base: "c.txt"}}
+	events := []m.Event{
+		m.FileScanned{File: &m.File{Id: id, Size: 42, ModTime: modTime, Hash: m.Hash{1, 2, 3}}},
+		m.ArchiveScanned{Root: "origin"},
+		m.FileCopied{Hash: m.Hash{1, 2, 3}, From: id, To: []m.Id{{Root: "copy", Name: id.Name}}},
+		m.CopyingProgress(7),
+		m.Tick(modTime),
+		m.ScreenSize{Width: 80, Height: 24},
+		m.MouseTarget{Command: m.SelectFile(id)},
+		m.MouseTarget{Command: w.SortBySize},
+		m.Scroll{Lines: -1},
+		m.TextInput{Rune: 'ö'},
+		m.Error{Id: id, Error: errors.New("permission denied")},
+		m.KeepOne{},
+	}
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	recorder, err := New(path, []m.Root{"origin", "copy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := stream.NewStream[m.Event]("test")
+	s.Tap(recorder.Record)
+	for _, event := range events {
+		s.Push(event)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec.Roots, []m.Root{"origin", "copy"}) {
+		t.Error("Unexpected roots", rec.Roots)
+	}
+	if len(rec.events) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(rec.events))
+	}
+	for i, event := range rec.events {
+		if err, ok := event.(m.Error); ok {
+			if err.Id != id || err.Error.Error() != "permission denied" {
+				t.Error("Unexpected error event", err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(event, events[i]) {
+			t.Errorf("Event %d: expected %#v, got %#v", i, events[i], event)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package recorder
+
+import (
+	m "arch/model"
+	"arch/stream"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type Recording struct {
+	Roots  []m.Root
+	times  []time.Time
+	events []m.Event
+}
+
+func Load(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("%s: missing header", path)
+	}
+	var h header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("%s: header: %w", path, err)
+	}
+	rec := &Recording{Roots: h.Roots}
+	for line := 2; scanner.Scan(); line++ {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		event, err := decode(r.Type, r.Event)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rec.times = append(rec.times, r.Time)
+		rec.events = append(rec.events, event)
+	}
+	return rec, scanner.Err()
+}
+
+// Play pushes the recorded events keeping their original spacing divided by speed;
+// a speed of zero or less pushes them without delay. Quit is pushed at the end.
+func (rec *Recording) Play(events *stream.Stream[m.Event], speed float64) {
+	start := time.Now()
+	for i, event := range rec.events {
+		if speed > 0 {
+			offset := time.Duration(float64(rec.times[i].Sub(rec.times[0])) / speed)
+			time.Sleep(time.Until(start.Add(offset)))
+		}
+		events.Push(event)
+	}
+	events.Push(m.Quit{})
+}
+
+type replayFs struct{}
+
+type replayScanner struct{}
+
+// ReplayFs is a file system whose scanners ignore all commands;
+// during replay the file events come from the recording.
+func ReplayFs() m.FS {
+	return replayFs{}
+}
+
+func (replayFs) NewArchiveScanner(root m.Root) m.ArchiveScanner {
+	return replayScanner{}
+}
+
+func (replayScanner) Send(cmd m.FileCommand) {}
+
+func (replayScanner) Close() {}
@@ -1,6 +1,7 @@
 package tcell
 
 import (
+	"arch/keys"
 	"arch/lifecycle"
 	m "arch/model"
 	"arch/stream"
@@ -13,12 +14,19 @@ import (
 type tcellRenderer struct {
 	lc               *lifecycle.Lifecycle
 	controllerEvents *stream.Stream[m.Event]
+	keys             *keys.Registry
 
 	commands         *stream.Stream[inEvent]
 	screen           tcell.Screen
 	mouseTargetAreas []w.MouseTargetArea
 	scrollAreas      []w.ScrollArea
+	colors           int
+	textInput        bool
+	modal            bool
+	dragging         bool
+	dragCommand      any
 	sync             bool
+	cells            [][]w.Cell
 }
 
 type inEvent interface {
@@ -31,6 +39,12 @@ type screenCommand struct {
 
 func (screenCommand) incoming() {}
 
+type suspendCommand struct {
+	run func()
+}
+
+func (suspendCommand) incoming() {}
+
 type quitCommand struct{}
 
 func (quitCommand) incoming() {}
@@ -41,7 +55,7 @@ type tcellEvent struct {
 
 func (tcellEvent) incoming() {}
 
-func NewRenderer(lc *lifecycle.Lifecycle, controllerEvents *stream.Stream[m.Event]) (w.Renderer, error) {
+func NewRenderer(lc *lifecycle.Lifecycle, controllerEvents *stream.Stream[m.Event], keys *keys.Registry) (w.Renderer, error) {
 	screen, err := tcell.NewScreen()
 	if err != nil {
 		return nil, err
@@ -50,11 +64,16 @@ func NewRenderer(lc *lifecycle.Lifecycle, controllerEvents *stream.Stream[m.Even
 		return nil, err
 	}
 	screen.EnableMouse()
+	if screen.Colors() < 8 {
+		w.SetTheme(w.MonochromeTheme())
+	}
 
 	renderer := &tcellRenderer{
 		lc:               lc,
 		controllerEvents: controllerEvents,
+		keys:             keys,
 		screen:           screen,
+		colors:           screen.Colors(),
 		commands:         stream.NewStream[inEvent]("tcell"),
 	}
 	go renderer.handleEvents()
@@ -67,6 +86,11 @@ func (r *tcellRenderer) Push(screen *w.Screen) {
 	r.commands.Push(screenCommand{screen})
 }
 
+func (r *tcellRenderer) Suspend(run func()) bool {
+	r.commands.Push(suspendCommand{run})
+	return true
+}
+
 func (r *tcellRenderer) Quit() {
 	r.commands.Push(quitCommand{})
 }
@@ -90,7 +114,11 @@ func (r *tcellRenderer) handleEvents() {
 					r.renderScreen(cmd.Screen)
 				}
 
+			case suspendCommand:
+				r.suspend(cmd.run)
+
 			case quitCommand:
+				r.commands.Close()
 				r.screen.Fini()
 				return
 
@@ -104,12 +132,24 @@ func (r *tcellRenderer) handleEvents() {
 	}
 }
 
+func (r *tcellRenderer) suspend(run func()) {
+	if err := r.screen.Suspend(); err != nil {
+		log.Printf("### failed to suspend screen: %v", err)
+		return
+	}
+	run()
+	if err := r.screen.Resume(); err != nil {
+		log.Printf("### failed to resume screen: %v", err)
+	}
+	r.sync = true
+}
+
 func (r *tcellRenderer) handleTcellEvent(event tcell.Event) bool {
 	switch event := event.(type) {
 	case *tcell.EventResize:
 		r.sync = true
 		x, y := event.Size()
-		r.controllerEvents.Push(m.ScreenSize{Width: x, Height: y})
+		r.controllerEvents.PushNow(m.ScreenSize{Width: x, Height: y})
 
 	case *tcell.EventMouse:
 		r.handleMouseEvent(event)
@@ -130,18 +170,28 @@ func (r *tcellRenderer) renderScreen(screen *w.Screen) {
 	r.scrollAreas = make([]w.ScrollArea, len(screen.ScrollAreas))
 	copy(r.scrollAreas, screen.ScrollAreas)
 
+	r.textInput = screen.TextInput
+	r.modal = screen.Modal
+
+	full := r.sync || len(r.cells) != len(screen.Cells) ||
+		len(r.cells) > 0 && len(r.cells[0]) != len(screen.Cells[0])
 	for y := range screen.Cells {
 		for x, cell := range screen.Cells[y] {
+			if !full && r.cells[y][x] == cell {
+				continue
+			}
 			style := tcell.StyleDefault.
-				Foreground(tcell.PaletteColor(int(cell.Style.FG))).
-				Background(tcell.PaletteColor(int(cell.Style.BG))).
+				Foreground(r.color(cell.Style.FG)).
+				Background(r.color(cell.Style.BG)).
 				Bold(cell.Style.Flags&w.Bold == w.Bold).
 				Italic(cell.Style.Flags&w.Italic == w.Italic).
-				Reverse(cell.Style.Flags&w.Reverse == w.Reverse)
+				Reverse(cell.Style.Flags&w.Reverse == w.Reverse).
+				Underline(cell.Style.Flags&w.Underline == w.Underline)
 
 			r.screen.SetContent(x, y, cell.Rune, nil, style)
 		}
 	}
+	r.cells = screen.Cells
 	if r.sync {
 		r.screen.Sync()
 		r.sync = false
@@ -150,14 +200,39 @@ func (r *tcellRenderer) renderScreen(screen *w.Screen) {
 	}
 }
 
+func (r *tcellRenderer) color(color w.Color) tcell.Color {
+	color = color.Downsample(r.colors)
+	switch {
+	case color.IsPalette():
+		return tcell.PaletteColor(int(color.Index()))
+	case color.IsRGB():
+		red, green, blue := color.RGB()
+		return tcell.NewRGBColor(int32(red), int32(green), int32(blue))
+	}
+	return tcell.ColorDefault
+}
+
 func (r *tcellRenderer) handleTcellEvents() {
+	buttonDown := false
 	for {
 		event := r.screen.PollEvent()
+		if event == nil {
+			return
+		}
 		for {
-			if ev, mouseEvent := event.(*tcell.EventMouse); !mouseEvent || ev.Buttons() != 0 {
+			ev, mouseEvent := event.(*tcell.EventMouse)
+			if !mouseEvent || ev.Buttons() != 0 {
+				buttonDown = mouseEvent
+				break
+			}
+			if buttonDown {
+				buttonDown = false
 				break
 			}
 			event = r.screen.PollEvent()
+			if event == nil {
+				return
+			}
 		}
 
 		if event != nil {
@@ -166,58 +241,33 @@ func (r *tcellRenderer) handleTcellEvents() {
 	}
 }
 
-func (device *tcellRenderer) handleKeyEvent(key *tcell.EventKey) {
-	log.Printf("### key: %q", key.Name())
-	switch key.Name() {
-	case "Ctrl+C":
-		device.controllerEvents.Push(m.Quit{})
-
-	case "Enter":
-		device.controllerEvents.Push(m.Open{})
-
-	// case "Esc":
-
-	case "Ctrl+R":
-		device.controllerEvents.Push(m.RevealInFinder{})
-
-	case "Home":
-		device.controllerEvents.Push(m.SelectFirst{})
-
-	case "End":
-		device.controllerEvents.Push(m.SelectLast{})
-
-	case "PgUp":
-		device.controllerEvents.Push(m.PgUp{})
-
-	case "PgDn":
-		device.controllerEvents.Push(m.PgDn{})
-
-	case "Up":
-		device.controllerEvents.Push(m.MoveSelection{Lines: -1})
-
-	case "Down":
-		device.controllerEvents.Push(m.MoveSelection{Lines: 1})
-
-	case "Left":
-		device.controllerEvents.Push(m.Exit{})
-
-	case "Right":
-		device.controllerEvents.Push(m.Enter{})
-
-	case "Ctrl+K":
-		device.controllerEvents.Push(m.KeepOne{})
-
-	case "Ctrl+A":
-		device.controllerEvents.Push(m.KeepAll{})
+func (r *tcellRenderer) handleKeyEvent(key *tcell.EventKey) {
+	name := keyName(key)
+	log.Printf("### key: %q", name)
+	if event, ok := r.keys.Route(name, r.modal, r.textInput); ok {
+		r.controllerEvents.PushNow(event)
+	}
+}
 
-	case "Tab":
-		device.controllerEvents.Push(m.Tab{})
+func keyName(key *tcell.EventKey) string {
+	switch key.Key() {
+	case tcell.KeyRune:
+		name := string(key.Rune())
+		if key.Rune() == ' ' {
+			name = "Space"
+		}
+		if key.Modifiers()&tcell.ModAlt != 0 {
+			name = "Alt+" + name
+		}
+		return name
 
-	case "Backspace2": // Ctrl+Delete
-		device.controllerEvents.Push(m.Delete{})
-	case "F12":
-		device.controllerEvents.Push(m.Debug{})
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if key.Modifiers()&tcell.ModAlt != 0 {
+			return "Alt+Backspace"
+		}
+		return "Backspace"
 	}
+	return key.Name()
 }
 
 func (d *tcellRenderer) handleMouseEvent(event *tcell.EventMouse) {
@@ -229,20 +279,32 @@ func (d *tcellRenderer) handleMouseEvent(event *tcell.EventMouse) {
 				target.Position.Y <= y && target.Position.Y+target.Size.Height > y {
 
 				if event.Buttons() == 512 {
-					d.controllerEvents.Push(m.Scroll{Command: target.Command, Lines: 1})
+					d.controllerEvents.PushNow(m.Scroll{Command: target.Command, Lines: 1})
 				} else {
-					d.controllerEvents.Push(m.Scroll{Command: target.Command, Lines: -1})
+					d.controllerEvents.PushNow(m.Scroll{Command: target.Command, Lines: -1})
 				}
 				return
 			}
 		}
 	}
 
+	if event.Buttons() == tcell.ButtonNone {
+		d.dragging = false
+		return
+	}
+
 	for _, target := range d.mouseTargetAreas {
 		if target.Position.X <= x && target.Position.X+target.Size.Width > x &&
 			target.Position.Y <= y && target.Position.Y+target.Size.Height > y {
 
-			d.controllerEvents.Push(m.MouseTarget{Command: target.Command})
+			if !d.dragging {
+				d.dragging = true
+				d.dragCommand = target.Command
+				d.controllerEvents.PushNow(m.MouseTarget{Command: target.Command})
+			} else if d.dragCommand != target.Command {
+				d.dragCommand = target.Command
+				d.controllerEvents.PushNow(m.MouseDrag{Command: target.Command})
+			}
 			return
 		}
 	}
@@ -0,0 +1,97 @@
+package text
+
+import (
+	w "arch/widgets"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Renderer is a headless w.Renderer that keeps the last pushed screen.
+type Renderer struct {
+	lock   sync.Mutex
+	screen *w.Screen
+	frames int
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{}
+}
+
+func (r *Renderer) Push(screen *w.Screen) {
+	r.lock.Lock()
+	r.screen = screen
+	r.frames++
+	r.lock.Unlock()
+}
+
+// Suspend does not run the function, a headless renderer has no terminal to hand over.
+func (r *Renderer) Suspend(run func()) bool {
+	return false
+}
+
+func (r *Renderer) Quit() {}
+
+func (r *Renderer) Screen() *w.Screen {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.screen
+}
+
+func (r *Renderer) Frames() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.frames
+}
+
+// Render returns the screen as plain text followed by a map of the same size
+// naming the style of every cell, and a legend for the style names.
+func Render(screen *w.Screen) string {
+	buf := &strings.Builder{}
+	for _, row := range screen.Cells {
+		line := make([]rune, len(row))
+		for x, cell := range row {
+			line[x] = cell.Rune
+			if cell.Rune == 0 {
+				line[x] = ' '
+			}
+		}
+		fmt.Fprintln(buf, strings.TrimRight(string(line), " "))
+	}
+
+	styles := []w.Style{}
+	names := map[w.Style]rune{}
+	fmt.Fprintln(buf, "--- styles")
+	for _, row := range screen.Cells {
+		line := make([]rune, len(row))
+		for x, cell := range row {
+			name, ok := names[cell.Style]
+			if !ok {
+				name = styleName(len(styles))
+				names[cell.Style] = name
+				styles = append(styles, cell.Style)
+			}
+			line[x] = name
+		}
+		fmt.Fprintln(buf, string(line))
+	}
+
+	fmt.Fprintln(buf, "--- legend")
+	for _, style := range styles {
+		fmt.Fprintf(buf, "%c: fg=%s bg=%s", names[style], style.FG, style.BG)
+		if style.Flags != 0 {
+			fmt.Fprintf(buf, " %s", style.Flags)
+		}
+		fmt.Fprintln(buf)
+	}
+	return buf.String()
+}
+
+const styleNames = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func styleName(idx int) rune {
+	if idx < len(styleNames) {
+		return rune(styleNames[idx])
+	}
+	return '?'
+}
@@ -0,0 +1,355 @@
+package web
+
+import (
+	"arch/keys"
+	"arch/lifecycle"
+	m "arch/model"
+	"arch/stream"
+	w "arch/widgets"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+//go:embed page.html
+var page []byte
+
+const tokenCookie = "arch-token"
+
+// maxScreenSize bounds the size a client can ask for, in cells in either direction.
+const maxScreenSize = 1000
+
+type webRenderer struct {
+	lc               *lifecycle.Lifecycle
+	controllerEvents *stream.Stream[m.Event]
+	keys             *keys.Registry
+	server           *http.Server
+	token            string
+
+	lock        sync.Mutex
+	updated     *sync.Cond
+	screen      *w.Screen
+	version     int
+	quit        bool
+	conns       map[*wsConn]struct{}
+	dragging    bool
+	dragCommand any
+}
+
+// NewRenderer serves the screen to browsers connecting to addr, for example "localhost:8080".
+// Without a host in addr it listens on 127.0.0.1 only. Browsers have to open the returned URL,
+// which carries a random token, before the page and its socket are served.
+func NewRenderer(lc *lifecycle.Lifecycle, controllerEvents *stream.Stream[m.Event], keys *keys.Registry, addr string) (w.Renderer, string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, "", err
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		listener.Close()
+		return nil, "", err
+	}
+	r := &webRenderer{
+		lc:               lc,
+		controllerEvents: controllerEvents,
+		keys:             keys,
+		token:            hex.EncodeToString(token),
+		conns:            map[*wsConn]struct{}{},
+	}
+	r.updated = sync.NewCond(&r.lock)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", r.servePage)
+	mux.HandleFunc("/ws", r.serveSocket)
+	r.server = &http.Server{Handler: mux}
+
+	lc.Started()
+	go func() {
+		if err := r.server.Serve(listener); err != http.ErrServerClosed {
+			log.Printf("### web renderer: %v", err)
+		}
+	}()
+	return r, fmt.Sprintf("http://%s/?token=%s", listener.Addr(), r.token), nil
+}
+
+func (r *webRenderer) Push(screen *w.Screen) {
+	r.lock.Lock()
+	r.screen = screen
+	r.version++
+	r.updated.Broadcast()
+	r.lock.Unlock()
+}
+
+// Suspend does not run the function: the browser cannot reach the terminal of the server.
+func (r *webRenderer) Suspend(run func()) bool {
+	return false
+}
+
+// Quit closes the server and the sockets, which the server does not track once upgraded.
+func (r *webRenderer) Quit() {
+	r.lock.Lock()
+	r.quit = true
+	r.updated.Broadcast()
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.lock.Unlock()
+	r.server.Close()
+	r.lc.Done()
+}
+
+// authorized tells if the request has the token, either in the URL or in the cookie set
+// when the page was opened with it.
+func (r *webRenderer) authorized(req *http.Request) bool {
+	token := req.URL.Query().Get("token")
+	if cookie, err := req.Cookie(tokenCookie); err == nil && token == "" {
+		token = cookie.Value
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.token)) == 1
+}
+
+func (r *webRenderer) servePage(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(rw, req)
+		return
+	}
+	if !r.authorized(req) {
+		http.Error(rw, "open the URL printed on start", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(rw, &http.Cookie{Name: tokenCookie, Value: r.token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Write(page)
+}
+
+func (r *webRenderer) serveSocket(rw http.ResponseWriter, req *http.Request) {
+	if !r.authorized(req) {
+		http.Error(rw, "missing token", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrade(rw, req)
+	if err != nil {
+		log.Printf("### web renderer: %v", err)
+		return
+	}
+	r.lock.Lock()
+	if r.quit {
+		r.lock.Unlock()
+		conn.Close()
+		return
+	}
+	r.conns[conn] = struct{}{}
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		delete(r.conns, conn)
+		r.lock.Unlock()
+		conn.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		r.sendScreens(conn, done)
+	}()
+	defer func() {
+		close(done)
+		r.lock.Lock()
+		r.updated.Broadcast()
+		r.lock.Unlock()
+	}()
+
+	for {
+		data, err := conn.ReadMessage()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("### web renderer: %v", err)
+			return
+		}
+		var msg input
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("### web renderer: bad message %q: %v", data, err)
+			continue
+		}
+		r.handleInput(msg)
+	}
+}
+
+type update struct {
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Full   bool    `json:"full"`
+	Cells  [][]any `json:"cells"`
+}
+
+// sendScreens sends every new screen to the client as the cells
+// that differ from the screen it received before.
+func (r *webRenderer) sendScreens(conn *wsConn, done chan struct{}) {
+	var prev [][]w.Cell
+	sent := 0
+	for {
+		r.lock.Lock()
+		for !r.quit && r.version == sent && !closed(done) {
+			r.updated.Wait()
+		}
+		if r.quit || closed(done) {
+			r.lock.Unlock()
+			return
+		}
+		screen := r.screen
+		sent = r.version
+		r.lock.Unlock()
+
+		msg := diff(prev, screen.Cells)
+		prev = screen.Cells
+		if !msg.Full && len(msg.Cells) == 0 {
+			continue
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("### web renderer: %v", err)
+			return
+		}
+		if err := conn.WriteText(data); err != nil {
+			return
+		}
+	}
+}
+
+func closed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func diff(prev, cells [][]w.Cell) update {
+	msg := update{Height: len(cells), Cells: [][]any{}}
+	if len(cells) > 0 {
+		msg.Width = len(cells[0])
+	}
+	msg.Full = len(prev) != len(cells) || len(prev) > 0 && len(prev[0]) != msg.Width
+	for y, row := range cells {
+		for x, cell := range row {
+			if !msg.Full && prev[y][x] == cell {
+				continue
+			}
+			r := cell.Rune
+			if r == 0 {
+				r = ' '
+			}
+			msg.Cells = append(msg.Cells, []any{x, y, string(r), cssColor(cell.Style.FG), cssColor(cell.Style.BG), cell.Style.Flags})
+		}
+	}
+	return msg
+}
+
+func cssColor(color w.Color) string {
+	if color == w.ColorDefault {
+		return ""
+	}
+	red, green, blue := color.RGB()
+	return fmt.Sprintf("#%02x%02x%02x", red, green, blue)
+}
+
+type input struct {
+	Type    string `json:"type"`
+	Key     string `json:"key"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Buttons int    `json:"buttons"`
+	Lines   int    `json:"lines"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+func (r *webRenderer) handleInput(msg input) {
+	if event := r.inputEvent(msg); event != nil {
+		r.controllerEvents.PushNow(event)
+	}
+}
+
+// inputEvent translates the input under the lock. The event is pushed after unlocking,
+// with PushNow, so that input never waits for the scanners filling the stream.
+func (r *webRenderer) inputEvent(msg input) m.Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	screen := r.screen
+
+	switch msg.Type {
+	case "resize":
+		return m.ScreenSize{Width: clamp(msg.Width, 1, maxScreenSize), Height: clamp(msg.Height, 1, maxScreenSize)}
+
+	case "key":
+		modal, textInput := screen != nil && screen.Modal, screen != nil && screen.TextInput
+		if event, ok := r.keys.Route(msg.Key, modal, textInput); ok {
+			return event
+		}
+
+	case "wheel":
+		if screen == nil {
+			return nil
+		}
+		for _, area := range screen.ScrollAreas {
+			if inside(msg.X, msg.Y, area.Position, area.Size) {
+				return m.Scroll{Command: area.Command, Lines: msg.Lines}
+			}
+		}
+
+	case "mouse":
+		if msg.Buttons == 0 {
+			r.dragging = false
+			return nil
+		}
+		if screen == nil {
+			return nil
+		}
+		for _, target := range screen.MouseTargets {
+			if !inside(msg.X, msg.Y, target.Position, target.Size) {
+				continue
+			}
+			if !r.dragging {
+				r.dragging = true
+				r.dragCommand = target.Command
+				return m.MouseTarget{Command: target.Command}
+			} else if r.dragCommand != target.Command {
+				r.dragCommand = target.Command
+				return m.MouseDrag{Command: target.Command}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func inside(x, y int, pos w.Position, size w.Size) bool {
+	return pos.X <= x && pos.X+size.Width > x && pos.Y <= y && pos.Y+size.Height > y
+}
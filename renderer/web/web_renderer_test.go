@@ -0,0 +1,122 @@
+package web
+
+import (
+	"arch/keys"
+	"arch/lifecycle"
+	m "arch/model"
+	"arch/stream"
+	w "arch/widgets"
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAndQuit(t *testing.T) {
+	registry, err := keys.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, pageURL, err := NewRenderer(lifecycle.New(), stream.NewStream[m.Event]("test"), registry, ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := url.Parse(pageURL)
+	if !strings.HasPrefix(page.Host, "127.0.0.1:") {
+		t.Errorf("Expected to listen on localhost only, got %s", page.Host)
+	}
+
+	resp, err := http.Get("http://" + page.Host + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected the page to be forbidden without token, got %s", resp.Status)
+	}
+	resp, err = http.Get(pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(resp.Cookies()) != 1 {
+		t.Fatalf("Expected the page with a cookie, got %s %v", resp.Status, resp.Cookies())
+	}
+	cookie := resp.Cookies()[0]
+
+	dial := func(header string) (net.Conn, *http.Response) {
+		conn, err := net.Dial("tcp", page.Host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: "+page.Host+"\r\n"+header+
+			"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n"+
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, resp
+	}
+	conn, resp := dial("")
+	conn.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected the socket to be forbidden without token, got %s", resp.Status)
+	}
+	conn, resp = dial("Cookie: " + cookie.Name + "=" + cookie.Value + "\r\n")
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected the socket with the cookie, got %s", resp.Status)
+	}
+
+	renderer.Quit()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		t.Errorf("Expected the socket to be closed on quit, got %v", err)
+	}
+}
+
+func TestInputDoesNotWaitForFullStream(t *testing.T) {
+	registry, err := keys.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := stream.NewBounded[m.Event]("test", 1, stream.Block)
+	events.Push(m.Tick{})
+	renderer, _, err := NewRenderer(lifecycle.New(), events, registry, ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer renderer.Quit()
+	defer events.Close()
+
+	pushed := make(chan struct{})
+	go func() {
+		renderer.(*webRenderer).handleInput(input{Type: "resize", Width: 80, Height: 24})
+		renderer.Push(w.NewScreen(m.ScreenSize{Width: 80, Height: 24}))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Input waits for the full stream")
+	}
+	if got := events.TryPull(); len(got) != 2 {
+		t.Error("Expected the input queued after the tick, got", got)
+	}
+}
+
+func TestResizeIsClamped(t *testing.T) {
+	r := &webRenderer{}
+	for _, msg := range []input{{Type: "resize", Width: -5, Height: 1 << 40}, {Type: "resize", Width: 0, Height: 0}} {
+		size := r.inputEvent(msg).(m.ScreenSize)
+		if size.Width < 1 || size.Width > maxScreenSize || size.Height < 1 || size.Height > maxScreenSize {
+			t.Errorf("Resize to %dx%d gave %v", msg.Width, msg.Height, size)
+		}
+		w.NewScreen(size)
+	}
+}
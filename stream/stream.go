@@ -1,49 +1,252 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"sync"
 )
 
+var ErrClosed = errors.New("stream closed")
+
+// Policy decides what Push does when a bounded stream is full.
+type Policy int
+
+const (
+	// Block makes Push wait until Pull makes room.
+	Block Policy = iota
+	// DropNewest discards the pushed message.
+	DropNewest
+	// DropOldest discards the oldest queued message to make room.
+	DropOldest
+)
+
 type Stream[T any] struct {
 	name     string
+	capacity int
+	policy   Policy
 	elements []T
+	tap      func(T)
+	coalesce func(T) (any, bool)
+	queued   map[any]struct{}
+	closed   bool
+	stats    Stats
+	signal   chan struct{}
 	*sync.Cond
 }
 
+// Stats are counters for monitoring a stream.
+type Stats struct {
+	Name      string
+	Len       int
+	MaxLen    int
+	Pushed    uint64
+	Pulled    uint64
+	Dropped   uint64
+	Blocked   uint64
+	Coalesced uint64
+	Capacity  int
+}
+
+// NewStream returns an unbounded stream.
 func NewStream[T any](name string) *Stream[T] {
+	return NewBounded[T](name, 0, Block)
+}
+
+// NewBounded returns a stream holding at most capacity messages; zero means unbounded.
+func NewBounded[T any](name string, capacity int, policy Policy) *Stream[T] {
 	return &Stream[T]{
-		Cond: sync.NewCond(&sync.Mutex{}),
-		name: name,
+		Cond:     sync.NewCond(&sync.Mutex{}),
+		name:     name,
+		capacity: capacity,
+		policy:   policy,
 	}
 }
 
-func (s *Stream[T]) Push(msg T) {
+// Tap calls tap with every message pushed from now on, in the order they are pushed.
+// Tap is called with the stream locked, so it has to hand the message off without
+// blocking, or it stalls every Push and Pull.
+func (s *Stream[T]) Tap(tap func(T)) {
 	s.Cond.L.Lock()
-	s.elements = append(s.elements, msg)
-	s.Cond.Signal()
+	s.tap = tap
 	s.Cond.L.Unlock()
 }
 
+// Coalesce makes Push replace a queued message having the same key as the pushed one.
+// The replaced message is removed and the new one is appended, so messages without
+// a key keep their order relative to the others.
+func (s *Stream[T]) Coalesce(key func(T) (any, bool)) {
+	s.Cond.L.Lock()
+	s.coalesce = key
+	s.queued = map[any]struct{}{}
+	s.Cond.L.Unlock()
+}
+
+// Push queues the message. It returns false if the stream is closed or the message was dropped.
+func (s *Stream[T]) Push(msg T) bool {
+	return s.push(msg, true)
+}
+
+// PushNow queues the message even if the stream is full, for messages which must neither
+// wait nor be dropped, like user input. It returns false if the stream is closed.
+func (s *Stream[T]) PushNow(msg T) bool {
+	return s.push(msg, false)
+}
+
+func (s *Stream[T]) push(msg T, bounded bool) bool {
+	s.Cond.L.Lock()
+	defer s.Cond.L.Unlock()
+
+	key, keyed := s.key(msg)
+	s.replace(key, keyed)
+	if bounded && s.full() && !s.closed {
+		switch s.policy {
+		case Block:
+			s.stats.Blocked++
+			for s.full() && !s.closed {
+				s.Cond.Wait()
+			}
+			// Another push may have queued the key while this one waited.
+			s.replace(key, keyed)
+		case DropNewest:
+			s.stats.Dropped++
+			return false
+		case DropOldest:
+			s.stats.Dropped++
+			if key, ok := s.key(s.elements[0]); ok {
+				delete(s.queued, key)
+			}
+			var zero T
+			s.elements[0] = zero
+			s.elements = s.elements[1:]
+		}
+	}
+	if s.closed {
+		return false
+	}
+	if s.tap != nil {
+		s.tap(msg)
+	}
+	s.elements = append(s.elements, msg)
+	if keyed {
+		s.queued[key] = struct{}{}
+	}
+	s.stats.Pushed++
+	if len(s.elements) > s.stats.MaxLen {
+		s.stats.MaxLen = len(s.elements)
+	}
+	s.wake()
+	return true
+}
+
+// replace removes the queued message having the key, which the pushed one replaces.
+func (s *Stream[T]) replace(key any, keyed bool) {
+	if !keyed || s.closed {
+		return
+	}
+	if _, ok := s.queued[key]; ok {
+		s.remove(key)
+		s.stats.Coalesced++
+	}
+}
+
+// wake wakes the waiting Pull calls, PullContext waits for the signal channel.
+func (s *Stream[T]) wake() {
+	s.Cond.Broadcast()
+	if s.signal != nil {
+		close(s.signal)
+		s.signal = nil
+	}
+}
+
+func (s *Stream[T]) key(msg T) (any, bool) {
+	if s.coalesce == nil {
+		return nil, false
+	}
+	return s.coalesce(msg)
+}
+
+func (s *Stream[T]) remove(key any) {
+	for i := len(s.elements) - 1; i >= 0; i-- {
+		if k, ok := s.key(s.elements[i]); ok && k == key {
+			s.elements = append(s.elements[:i], s.elements[i+1:]...)
+			delete(s.queued, key)
+			return
+		}
+	}
+}
+
+func (s *Stream[T]) full() bool {
+	return s.capacity > 0 && len(s.elements) >= s.capacity
+}
+
+// Pull waits for messages and returns all queued ones. After Close it returns
+// the remaining messages, and then an empty slice without waiting.
 func (s *Stream[T]) Pull() []T {
-	for {
-		s.Cond.L.Lock()
-		if len(s.elements) == 0 {
+	msgs, _ := s.PullContext(context.Background())
+	return msgs
+}
+
+// PullContext is Pull that gives up when ctx is done. It returns ErrClosed
+// once the stream is closed and drained.
+func (s *Stream[T]) PullContext(ctx context.Context) ([]T, error) {
+	s.Cond.L.Lock()
+	defer s.Cond.L.Unlock()
+	for len(s.elements) == 0 {
+		if s.closed {
+			return nil, ErrClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if ctx.Done() == nil {
 			s.Cond.Wait()
-			s.Cond.L.Unlock()
 			continue
-		} else {
-			msgs := s.elements
-			s.elements = []T{}
-			s.Cond.L.Unlock()
-			return msgs
 		}
+		if s.signal == nil {
+			s.signal = make(chan struct{})
+		}
+		signal := s.signal
+		s.Cond.L.Unlock()
+		select {
+		case <-signal:
+		case <-ctx.Done():
+		}
+		s.Cond.L.Lock()
 	}
+	return s.take(), nil
 }
 
 func (s *Stream[T]) TryPull() []T {
 	s.Cond.L.Lock()
+	defer s.Cond.L.Unlock()
+	return s.take()
+}
+
+func (s *Stream[T]) take() []T {
 	msgs := s.elements
 	s.elements = []T{}
-	s.Cond.L.Unlock()
+	if s.queued != nil {
+		s.queued = map[any]struct{}{}
+	}
+	s.stats.Pulled += uint64(len(msgs))
+	s.Cond.Broadcast()
 	return msgs
 }
+
+// Close wakes all waiting Push and Pull calls. Later pushes are ignored.
+func (s *Stream[T]) Close() {
+	s.Cond.L.Lock()
+	s.closed = true
+	s.wake()
+	s.Cond.L.Unlock()
+}
+
+func (s *Stream[T]) Stats() Stats {
+	s.Cond.L.Lock()
+	defer s.Cond.L.Unlock()
+	stats := s.stats
+	stats.Name = s.name
+	stats.Len = len(s.elements)
+	stats.Capacity = s.capacity
+	return stats
+}
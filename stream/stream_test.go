@@ -1,8 +1,10 @@
 package stream
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestStream(t *testing.T) {
@@ -24,3 +26,153 @@ func TestStream(t *testing.T) {
 	s.Push("f")
 	wg.Wait()
 }
+
+func TestDropPolicies(t *testing.T) {
+	newest := NewBounded[int]("newest", 2, DropNewest)
+	oldest := NewBounded[int]("oldest", 2, DropOldest)
+	for i := 1; i <= 4; i++ {
+		newest.Push(i)
+		oldest.Push(i)
+	}
+	if got := newest.TryPull(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Error("DropNewest: expected [1 2], got", got)
+	}
+	if got := oldest.TryPull(); len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Error("DropOldest: expected [3 4], got", got)
+	}
+	if stats := newest.Stats(); stats.Dropped != 2 || stats.Pushed != 2 || stats.MaxLen != 2 {
+		t.Error("Unexpected stats", stats)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	s := NewBounded[int]("block", 1, Block)
+	s.Push(1)
+	pushed := make(chan bool)
+	go func() {
+		pushed <- s.Push(2)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("Push did not block on a full stream")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if got := s.Pull(); len(got) != 1 || got[0] != 1 {
+		t.Error("Expected [1], got", got)
+	}
+	if !<-pushed {
+		t.Error("Expected blocked push to succeed")
+	}
+	if got := s.Pull(); len(got) != 1 || got[0] != 2 {
+		t.Error("Expected [2], got", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := NewStream[int]("close")
+	s.Push(1)
+	done := make(chan []int)
+	go func() {
+		s.Pull()
+		done <- s.Pull()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	if got := <-done; len(got) != 0 {
+		t.Error("Expected empty pull after close, got", got)
+	}
+	if s.Push(2) {
+		t.Error("Expected push to a closed stream to fail")
+	}
+	if _, err := s.PullContext(context.Background()); err != ErrClosed {
+		t.Error("Expected ErrClosed, got", err)
+	}
+}
+
+func TestPullContext(t *testing.T) {
+	s := NewStream[int]("context")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.PullContext(ctx); err != context.DeadlineExceeded {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	s := NewBounded[string]("coalesce", 4, Block)
+	s.Coalesce(func(msg string) (any, bool) {
+		if msg[0] == 'p' {
+			return msg[:2], true
+		}
+		return nil, false
+	})
+	for _, msg := range []string{"pa1", "file1", "pb1", "pa2", "file2", "pa3"} {
+		s.Push(msg)
+	}
+	want := []string{"file1", "pb1", "file2", "pa3"}
+	got := s.TryPull()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+	if stats := s.Stats(); stats.Coalesced != 2 {
+		t.Error("Expected 2 coalesced messages, got", stats.Coalesced)
+	}
+}
+
+func TestPullContextWakesOnPush(t *testing.T) {
+	s := NewStream[int]("context")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go s.Push(1)
+	if msgs, err := s.PullContext(ctx); err != nil || len(msgs) != 1 {
+		t.Errorf("Expected the pushed message, got %v, %v", msgs, err)
+	}
+	go s.Close()
+	if _, err := s.PullContext(ctx); err != ErrClosed {
+		t.Error("Expected ErrClosed, got", err)
+	}
+}
+
+func TestPushNowDoesNotWait(t *testing.T) {
+	s := NewBounded[int]("now", 1, Block)
+	s.Push(1)
+	if !s.PushNow(2) {
+		t.Error("Expected PushNow to queue on a full stream")
+	}
+	if got := s.TryPull(); len(got) != 2 || got[1] != 2 {
+		t.Error("Expected [1 2], got", got)
+	}
+}
+
+func TestCoalesceAfterBlockedPush(t *testing.T) {
+	s := NewBounded[string]("coalesce", 2, Block)
+	s.Coalesce(func(msg string) (any, bool) {
+		if msg[0] == 'p' {
+			return msg[:2], true
+		}
+		return nil, false
+	})
+	s.Push("file1")
+	s.Push("file2")
+	wg := &sync.WaitGroup{}
+	for _, msg := range []string{"pa1", "pa2"} {
+		wg.Add(1)
+		go func(msg string) {
+			s.Push(msg)
+			wg.Done()
+		}(msg)
+	}
+	for s.Stats().Blocked < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	s.TryPull()
+	wg.Wait()
+	if got := s.TryPull(); len(got) != 1 {
+		t.Error("Expected one progress message, got", got)
+	}
+}
@@ -0,0 +1,133 @@
+package widgets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Color uint32
+
+const (
+	ColorDefault Color = 0
+	colorPalette Color = 1 << 24
+	colorRGB     Color = 1 << 25
+)
+
+func Palette(idx byte) Color {
+	return colorPalette | Color(idx)
+}
+
+func RGB(r, g, b byte) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+func (c Color) IsPalette() bool {
+	return c&colorPalette != 0
+}
+
+func (c Color) IsRGB() bool {
+	return c&colorRGB != 0
+}
+
+func (c Color) Index() byte {
+	return byte(c)
+}
+
+func (c Color) RGB() (r, g, b byte) {
+	if c.IsPalette() {
+		c = paletteRGB(c.Index())
+	}
+	return byte(c >> 16), byte(c >> 8), byte(c)
+}
+
+// Downsample maps the color to the closest one a terminal with the given number of colors can show.
+func (c Color) Downsample(colors int) Color {
+	switch {
+	case c == ColorDefault || colors >= 1<<24:
+		return c
+	case colors < 8:
+		return ColorDefault
+	case c.IsPalette() && int(c.Index()) < colors:
+		return c
+	}
+	if colors > 256 {
+		colors = 256
+	}
+	r, g, b := c.RGB()
+	best, bestDist := 0, -1
+	for idx := 0; idx < colors; idx++ {
+		pr, pg, pb := paletteRGB(byte(idx)).RGB()
+		dr, dg, db := int(r)-int(pr), int(g)-int(pg), int(b)-int(pb)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = idx, dist
+		}
+	}
+	return Palette(byte(best))
+}
+
+var systemColors = [16]Color{
+	RGB(0, 0, 0), RGB(128, 0, 0), RGB(0, 128, 0), RGB(128, 128, 0),
+	RGB(0, 0, 128), RGB(128, 0, 128), RGB(0, 128, 128), RGB(192, 192, 192),
+	RGB(128, 128, 128), RGB(255, 0, 0), RGB(0, 255, 0), RGB(255, 255, 0),
+	RGB(0, 0, 255), RGB(255, 0, 255), RGB(0, 255, 255), RGB(255, 255, 255),
+}
+
+var cubeLevels = [6]byte{0, 95, 135, 175, 215, 255}
+
+func paletteRGB(idx byte) Color {
+	switch {
+	case idx < 16:
+		return systemColors[idx]
+	case idx < 232:
+		idx -= 16
+		return RGB(cubeLevels[idx/36], cubeLevels[idx/6%6], cubeLevels[idx%6])
+	}
+	gray := 8 + 10*(idx-232)
+	return RGB(gray, gray, gray)
+}
+
+func (c Color) String() string {
+	switch {
+	case c.IsPalette():
+		return strconv.Itoa(int(c.Index()))
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	return "default"
+}
+
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var idx byte
+	if err := json.Unmarshal(data, &idx); err == nil {
+		*c = Palette(idx)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if str == "default" {
+		*c = ColorDefault
+		return nil
+	}
+	if len(str) != 7 || !strings.HasPrefix(str, "#") {
+		return fmt.Errorf("invalid color %q", str)
+	}
+	rgb, err := strconv.ParseUint(str[1:], 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid color %q", str)
+	}
+	*c = RGB(byte(rgb>>16), byte(rgb>>8), byte(rgb))
+	return nil
+}
+
+func (c Color) MarshalJSON() ([]byte, error) {
+	if c.IsPalette() {
+		return json.Marshal(c.Index())
+	}
+	return json.Marshal(c.String())
+}
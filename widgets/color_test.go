@@ -0,0 +1,40 @@
+package widgets
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDownsample(t *testing.T) {
+	tests := []struct {
+		color  Color
+		colors int
+		want   Color
+	}{
+		{RGB(255, 0, 0), 1 << 24, RGB(255, 0, 0)},
+		{RGB(0, 95, 135), 256, Palette(24)},
+		{RGB(250, 10, 10), 16, Palette(9)},
+		{RGB(250, 10, 10), 8, Palette(1)},
+		{Palette(226), 16, Palette(11)},
+		{Palette(17), 8, Palette(4)},
+		{Palette(3), 8, Palette(3)},
+		{Palette(3), 0, ColorDefault},
+		{ColorDefault, 8, ColorDefault},
+	}
+	for _, test := range tests {
+		if got := test.color.Downsample(test.colors); got != test.want {
+			t.Errorf("%s.Downsample(%d): expected %s, got %s", test.color, test.colors, test.want, got)
+		}
+	}
+}
+
+func TestColorJSON(t *testing.T) {
+	var style Style
+	err := json.Unmarshal([]byte(`{"fg": "#ff8000", "bg": 17, "flags": ["bold", "reverse"]}`), &style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if style.FG != RGB(255, 128, 0) || style.BG != Palette(17) || style.Flags != Bold|Reverse {
+		t.Error("Unexpected style", style)
+	}
+}
@@ -0,0 +1,136 @@
+package widgets
+
+import (
+	m "arch/model"
+	"fmt"
+	"strings"
+)
+
+type overlay struct {
+	base    Widget
+	overlay Widget
+}
+
+// Overlay renders the overlay widget centered on top of the base widget.
+// Mouse and scroll targets of the base widget are dropped, making the overlay modal.
+func Overlay(base, over Widget) Widget {
+	return overlay{base: base, overlay: over}
+}
+
+func (o overlay) Constraint() Constraint {
+	return o.base.Constraint()
+}
+
+func (o overlay) Render(screen *Screen, pos Position, size Size) {
+	mouseTargets, scrollAreas := len(screen.MouseTargets), len(screen.ScrollAreas)
+	o.base.Render(screen, pos, size)
+	screen.MouseTargets = screen.MouseTargets[:mouseTargets]
+	screen.ScrollAreas = screen.ScrollAreas[:scrollAreas]
+	screen.TextInput = false
+	screen.Modal = true
+
+	overlaySize := o.overlay.Constraint().Size
+	if overlaySize.Width > size.Width {
+		overlaySize.Width = size.Width
+	}
+	if overlaySize.Height > size.Height {
+		overlaySize.Height = size.Height
+	}
+	overlayPos := Position{
+		X: pos.X + (size.Width-overlaySize.Width)/2,
+		Y: pos.Y + (size.Height-overlaySize.Height)/2,
+	}
+	o.overlay.Render(screen, overlayPos, overlaySize)
+}
+
+func (o overlay) String() string { return toString(o) }
+
+func (o overlay) ToString(buf *strings.Builder, offset string) {
+	fmt.Fprintf(buf, "%sOverlay(\n", offset)
+	o.base.ToString(buf, offset+"| ")
+	o.overlay.ToString(buf, offset+"| ")
+}
+
+type dialog struct {
+	title   string
+	lines   []string
+	buttons []string
+	focused int
+}
+
+func Dialog(title string, lines, buttons []string, focused int) Widget {
+	return dialog{title: title, lines: lines, buttons: buttons, focused: focused}
+}
+
+func (d dialog) Constraint() Constraint {
+	width := len([]rune(d.buttonsLine()))
+	for _, line := range append(d.lines, d.title) {
+		if width < len([]rune(line)) {
+			width = len([]rune(line))
+		}
+	}
+	width += 4
+	return Constraint{Size: Size{Width: width, Height: len(d.lines) + 3}}
+}
+
+func (d dialog) buttonsLine() string {
+	buttons := make([]string, len(d.buttons))
+	for i, button := range d.buttons {
+		buttons[i] = "[ " + button + " ]"
+	}
+	return strings.Join(buttons, " ")
+}
+
+func (d dialog) Render(screen *Screen, pos Position, size Size) {
+	if size.Width < 4 || size.Height < 3 {
+		return
+	}
+	style := screen.Style
+	screen.Style = theme.Dialog
+	defer func() { screen.Style = style }()
+
+	inner := size.Width - 2
+	title := []rune(d.title)
+	if len(title) > inner-2 {
+		title = title[:inner-2]
+	}
+	top := "┌─" + string(title) + strings.Repeat("─", inner-1-len(title)) + "┐"
+	Text(top).Render(screen, pos, Size{Width: size.Width, Height: 1})
+	y := pos.Y + 1
+	for _, line := range d.lines {
+		if y >= pos.Y+size.Height-2 {
+			break
+		}
+		Text("│ "+line).Width(size.Width-1).Render(screen, Position{X: pos.X, Y: y}, Size{Width: size.Width - 1, Height: 1})
+		Text("│").Render(screen, Position{X: pos.X + size.Width - 1, Y: y}, Size{Width: 1, Height: 1})
+		y++
+	}
+
+	buttonsLine := d.buttonsLine()
+	x := pos.X + (size.Width-len([]rune(buttonsLine)))/2
+	Text("│").Render(screen, Position{X: pos.X, Y: y}, Size{Width: 1, Height: 1})
+	Text(strings.Repeat(" ", size.Width-2)).Render(screen, Position{X: pos.X + 1, Y: y}, Size{Width: size.Width - 2, Height: 1})
+	Text("│").Render(screen, Position{X: pos.X + size.Width - 1, Y: y}, Size{Width: 1, Height: 1})
+	for i, button := range d.buttons {
+		label := "[ " + button + " ]"
+		width := len([]rune(label))
+		if x < pos.X+1 || x+width > pos.X+size.Width-1 {
+			break
+		}
+		buttonStyle := theme.Dialog
+		if i == d.focused {
+			buttonStyle.Flags ^= Reverse
+		}
+		MouseTarget(m.DialogButton(i), Styled(buttonStyle, Text(label))).Render(screen, Position{X: x, Y: y}, Size{Width: width, Height: 1})
+		x += width + 1
+	}
+	y++
+	bottom := "└" + strings.Repeat("─", inner) + "┘"
+	Text(bottom).Render(screen, Position{X: pos.X, Y: y}, Size{Width: size.Width, Height: 1})
+}
+
+func (d dialog) String() string { return toString(d) }
+
+func (d dialog) ToString(buf *strings.Builder, offset string) {
+	fmt.Fprintf(buf, "%sDialog(%q, %q, %q, %d)\n", offset, d.title, d.lines, d.buttons, d.focused)
+}
@@ -0,0 +1,49 @@
+package widgets
+
+import (
+	m "arch/model"
+	"testing"
+)
+
+func TestOverlayDialog(t *testing.T) {
+	screen := NewScreen(m.ScreenSize{Width: 40, Height: 10})
+	base := MouseTarget(m.SelectFile(m.Id{}), Text("base").Width(40))
+	dialog := Dialog(" Delete ", []string{"Delete 3 files?"}, []string{"Delete", "Cancel"}, 1)
+	Overlay(base, dialog).Render(screen, Position{}, Size{Width: 40, Height: 10})
+
+	if !screen.Modal {
+		t.Error("Expected modal screen")
+	}
+	if len(screen.MouseTargets) != 2 {
+		t.Fatal("Expected only dialog buttons as mouse targets, got", screen.MouseTargets)
+	}
+	for i, target := range screen.MouseTargets {
+		if target.Command != m.DialogButton(i) {
+			t.Errorf("Expected button %d, got %v", i, target.Command)
+		}
+	}
+}
+
+func TestNarrowPane(t *testing.T) {
+	info := &PaneInfo{Title: "a long command name", Rows: []string{"ok"}}
+	if got := Pane(info).Constraint().Size.Width; got < len(info.Title)+5 {
+		t.Errorf("Constraint width %d leaves out the title", got)
+	}
+	for width := 4; width < 30; width++ {
+		screen := NewScreen(m.ScreenSize{Width: width, Height: 5})
+		Pane(info).Render(screen, Position{}, Size{Width: width, Height: 5})
+		if corner := screen.Cells[0][width-1].Rune; corner != '┐' {
+			t.Errorf("Width %d: top right corner %q", width, corner)
+		}
+	}
+}
+
+func TestDialogFillsItsHeight(t *testing.T) {
+	dialog := Dialog(" Delete ", []string{"Delete 3 files?", "Deleted files cannot be restored."}, []string{"Delete", "Cancel"}, 0)
+	size := dialog.Constraint().Size
+	screen := NewScreen(m.ScreenSize{Width: size.Width, Height: size.Height})
+	dialog.Render(screen, Position{}, size)
+	if corner := screen.Cells[size.Height-1][0].Rune; corner != '└' {
+		t.Errorf("Bottom left corner %q, dialog constraint is %d rows high", corner, size.Height)
+	}
+}
@@ -0,0 +1,121 @@
+package widgets
+
+import (
+	m "arch/model"
+	"fmt"
+	"strings"
+)
+
+type HelpSection struct {
+	Title string
+	Lines []HelpLine
+}
+
+type HelpLine struct {
+	Keys string
+	Help string
+}
+
+// HelpRows formats help sections one row per line, with the keys column aligned.
+func HelpRows(sections []HelpSection) []string {
+	width := 0
+	for _, section := range sections {
+		for _, line := range section.Lines {
+			if width < len([]rune(line.Keys)) {
+				width = len([]rune(line.Keys))
+			}
+		}
+	}
+	rows := []string{}
+	for i, section := range sections {
+		if i > 0 {
+			rows = append(rows, "")
+		}
+		rows = append(rows, section.Title)
+		for _, line := range section.Lines {
+			pad := strings.Repeat(" ", width-len([]rune(line.Keys)))
+			rows = append(rows, "  "+line.Keys+pad+"  "+line.Help)
+		}
+	}
+	return rows
+}
+
+type PaneInfo struct {
+	Title  string
+	Rows   []string
+	Offset int
+}
+
+type pane struct {
+	title  string
+	rows   []string
+	offset int
+}
+
+// Pane renders scrollable rows of text in a box; it is meant to be shown with Overlay.
+func Pane(info *PaneInfo) Widget {
+	return pane{title: info.Title, rows: info.Rows, offset: info.Offset}
+}
+
+func (h pane) Constraint() Constraint {
+	width := len([]rune(h.title)) + 1
+	for _, row := range h.rows {
+		if width < len([]rune(row)) {
+			width = len([]rune(row))
+		}
+	}
+	return Constraint{Size: Size{Width: width + 4, Height: len(h.rows) + 2}}
+}
+
+func (h pane) Render(screen *Screen, pos Position, size Size) {
+	if size.Width < 4 || size.Height < 3 {
+		return
+	}
+	style := screen.Style
+	screen.Style = theme.Dialog
+	defer func() { screen.Style = style }()
+
+	inner := size.Width - 2
+	title := []rune(" " + h.title + " ")
+	if len(title) > inner-2 {
+		title = title[:inner-2]
+	}
+	top := "┌─" + string(title) + strings.Repeat("─", inner-1-len(title)) + "┐"
+	Text(top).Render(screen, pos, Size{Width: size.Width, Height: 1})
+
+	visible := size.Height - 2
+	offset := h.offset
+	if offset > len(h.rows)-visible {
+		offset = len(h.rows) - visible
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	Scroll(m.Scroll{}, Constraint{}, func(Size) Widget { return Spacer{} }).
+		Render(screen, Position{X: pos.X + 1, Y: pos.Y + 1}, Size{Width: inner, Height: visible})
+	for i := 0; i < visible; i++ {
+		row := ""
+		if offset+i < len(h.rows) {
+			row = h.rows[offset+i]
+		}
+		y := pos.Y + 1 + i
+		Text("│ "+row).Width(size.Width-1).Render(screen, Position{X: pos.X, Y: y}, Size{Width: size.Width - 1, Height: 1})
+		Text("│").Render(screen, Position{X: pos.X + size.Width - 1, Y: y}, Size{Width: 1, Height: 1})
+	}
+
+	hint := "Esc to close"
+	bottom := "└" + strings.Repeat("─", inner) + "┘"
+	if offset+visible < len(h.rows) {
+		hint = "↓ more, Esc to close"
+	}
+	if len([]rune(hint))+4 <= inner {
+		bottom = "└" + strings.Repeat("─", inner-len([]rune(hint))-3) + " " + hint + " ─┘"
+	}
+	Text(bottom).Render(screen, Position{X: pos.X, Y: pos.Y + size.Height - 1}, Size{Width: size.Width, Height: 1})
+}
+
+func (h pane) String() string { return toString(h) }
+
+func (h pane) ToString(buf *strings.Builder, offset string) {
+	fmt.Fprintf(buf, "%sPane(%q, %d rows, offset %d)\n", offset, h.title, len(h.rows), h.offset)
+}
@@ -26,6 +26,8 @@ type Screen struct {
 	MouseTargets []MouseTargetArea
 	ScrollAreas  []ScrollArea
 	Style        Style
+	TextInput    bool
+	Modal        bool
 }
 
 func NewScreen(size m.ScreenSize) *Screen {
@@ -40,5 +42,8 @@ func NewScreen(size m.ScreenSize) *Screen {
 
 type Renderer interface {
 	Push(*Screen)
+	// Suspend gives the terminal to run and restores the screen once it returns.
+	// Renderers without a terminal return false and do not call run.
+	Suspend(run func()) bool
 	Quit()
 }
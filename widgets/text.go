@@ -6,15 +6,16 @@ import (
 )
 
 type text struct {
-	runes []rune
-	width int
-	flex  int
-	pad   rune
+	runes     []rune
+	width     int
+	flex      int
+	pad       rune
+	highlight Match
 }
 
 func Text(txt string) *text {
 	runes := []rune(txt)
-	return &text{runes, len(runes), 0, ' '}
+	return &text{runes: runes, width: len(runes), pad: ' '}
 }
 
 func (t *text) Width(width int) *text {
@@ -32,6 +33,11 @@ func (t *text) Pad(r rune) *text {
 	return t
 }
 
+func (t *text) Highlight(match Match) *text {
+	t.highlight = match
+	return t
+}
+
 func (t *text) Constraint() Constraint {
 	return Constraint{Size: Size{Width: t.width, Height: 1}, Flex: Flex{X: t.flex, Y: 0}}
 }
@@ -40,8 +46,12 @@ func (t *text) Render(screen *Screen, pos Position, size Size) {
 	if size.Width < 1 {
 		return
 	}
+	highlight := t.highlight
 	if len(t.runes) > int(size.Width) {
 		t.runes = append(t.runes[:size.Width-1], '…')
+		if highlight.End > size.Width-1 {
+			highlight.End = size.Width - 1
+		}
 	}
 	diff := int(size.Width) - len(t.runes)
 	for diff > 0 {
@@ -49,8 +59,17 @@ func (t *text) Render(screen *Screen, pos Position, size Size) {
 		diff--
 	}
 
+	highlightStyle := screen.Style
+	highlightStyle.Flags |= theme.Match.Flags
+	if theme.Match.FG != ColorDefault {
+		highlightStyle.FG = theme.Match.FG
+	}
 	for x := 0; x < size.Width; x++ {
-		screen.Cells[pos.Y][pos.X+x] = Cell{Rune: t.runes[x], Style: screen.Style}
+		style := screen.Style
+		if x >= highlight.Start && x < highlight.End {
+			style = highlightStyle
+		}
+		screen.Cells[pos.Y][pos.X+x] = Cell{Rune: t.runes[x], Style: style}
 	}
 }
 
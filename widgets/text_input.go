@@ -0,0 +1,46 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+)
+
+type textInput struct {
+	text    string
+	focused bool
+}
+
+func TextInput(text string, focused bool) Widget {
+	return textInput{text: text, focused: focused}
+}
+
+func (t textInput) Constraint() Constraint {
+	return Constraint{Size: Size{Width: 1, Height: 1}, Flex: Flex{X: 1, Y: 0}}
+}
+
+func (t textInput) Render(screen *Screen, pos Position, size Size) {
+	if size.Width < 1 {
+		return
+	}
+	runes := []rune(t.text)
+	if t.focused {
+		screen.TextInput = true
+		runes = append(runes, '█')
+	}
+	if len(runes) > size.Width {
+		runes = append([]rune{'…'}, runes[len(runes)-size.Width+1:]...)
+	}
+	for x := 0; x < size.Width; x++ {
+		r := ' '
+		if x < len(runes) {
+			r = runes[x]
+		}
+		screen.Cells[pos.Y][pos.X+x] = Cell{Rune: r, Style: screen.Style}
+	}
+}
+
+func (t textInput) String() string { return toString(t) }
+
+func (t textInput) ToString(buf *strings.Builder, offset string) {
+	fmt.Fprintf(buf, "%sTextInput(%q, %v)\n", offset, t.text, t.focused)
+}
@@ -0,0 +1,20 @@
+package widgets
+
+import (
+	m "arch/model"
+	"testing"
+)
+
+func TestHighlightClippedWhenTruncated(t *testing.T) {
+	screen := NewScreen(m.ScreenSize{Width: 5, Height: 1})
+	Text("abcdefgh").Highlight(Match{Start: 2, End: 7}).Render(screen, Position{}, Size{Width: 5, Height: 1})
+
+	for x, cell := range screen.Cells[0] {
+		if highlighted := cell.Style != screen.Style; highlighted != (x == 2 || x == 3) {
+			t.Errorf("Cell %d %q highlighted: %v", x, cell.Rune, highlighted)
+		}
+	}
+	if screen.Cells[0][4].Rune != '…' {
+		t.Errorf("Expected the name to end with …, got %q", screen.Cells[0][4].Rune)
+	}
+}
@@ -0,0 +1,99 @@
+package widgets
+
+import (
+	"encoding/json"
+	"os"
+)
+
+type Theme struct {
+	Default       Style `json:"default"`
+	AppTitle      Style `json:"appTitle"`
+	StatusLine    Style `json:"statusLine"`
+	Archive       Style `json:"archive"`
+	ProgressBar   Style `json:"progressBar"`
+	ArchiveHeader Style `json:"archiveHeader"`
+	Breadcrumbs   Style `json:"breadcrumbs"`
+	File          Style `json:"file"`
+	Folder        Style `json:"folder"`
+	Resolved      Style `json:"resolved"`
+	Pending       Style `json:"pending"`
+	Duplicate     Style `json:"duplicate"`
+	Absent        Style `json:"absent"`
+	Match         Style `json:"match"`
+	Dialog        Style `json:"dialog"`
+	Symbols       bool  `json:"symbols"`
+}
+
+func DefaultTheme() Theme {
+	return Theme{
+		Default:       Style{FG: Palette(226), BG: Palette(17)},
+		AppTitle:      Style{FG: Palette(226), BG: Palette(0), Flags: Bold + Italic},
+		StatusLine:    Style{FG: Palette(230), BG: Palette(0), Flags: Italic},
+		Archive:       Style{FG: Palette(226), BG: Palette(0), Flags: Bold},
+		ProgressBar:   Style{FG: Palette(231), BG: Palette(19)},
+		ArchiveHeader: Style{FG: Palette(231), BG: Palette(8), Flags: Bold},
+		Breadcrumbs:   Style{FG: Palette(250), BG: Palette(17), Flags: Bold + Italic},
+		File:          Style{BG: Palette(17)},
+		Folder:        Style{BG: Palette(18)},
+		Resolved:      Style{FG: Palette(195)},
+		Pending:       Style{FG: Palette(214)},
+		Duplicate:     Style{FG: Palette(196)},
+		Absent:        Style{FG: Palette(196)},
+		Match:         Style{FG: Palette(51), Flags: Bold + Underline},
+		Dialog:        Style{FG: Palette(231), BG: Palette(24), Flags: Bold},
+	}
+}
+
+func MonochromeTheme() Theme {
+	return Theme{
+		AppTitle:      Style{Flags: Bold + Reverse},
+		StatusLine:    Style{Flags: Italic},
+		Archive:       Style{Flags: Bold},
+		ProgressBar:   Style{Flags: Reverse},
+		ArchiveHeader: Style{Flags: Bold + Reverse},
+		Breadcrumbs:   Style{Flags: Bold + Underline},
+		Folder:        Style{Flags: Bold},
+		Pending:       Style{Flags: Italic},
+		Duplicate:     Style{Flags: Bold + Underline},
+		Absent:        Style{Flags: Bold + Underline},
+		Match:         Style{Flags: Reverse},
+		Dialog:        Style{Flags: Bold},
+		Symbols:       true,
+	}
+}
+
+func Themes() map[string]Theme {
+	return map[string]Theme{
+		"default": DefaultTheme(),
+		"mono":    MonochromeTheme(),
+	}
+}
+
+// LoadTheme reads a theme file; styles missing from the file are taken from the default theme.
+func LoadTheme(path string) (Theme, error) {
+	theme := DefaultTheme()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return theme, err
+	}
+	err = json.Unmarshal(data, &theme)
+	return theme, err
+}
+
+var theme = DefaultTheme()
+
+func SetTheme(t Theme) {
+	theme = t
+}
+
+func (t Theme) stateStyle(state State) Style {
+	switch state {
+	case Pending:
+		return t.Pending
+	case Duplicate:
+		return t.Duplicate
+	case Absent:
+		return t.Absent
+	}
+	return t.Resolved
+}
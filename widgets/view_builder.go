@@ -8,22 +8,13 @@ import (
 	"time"
 )
 
-var (
-	styleDefault       = Style{FG: 226, BG: 17}
-	styleAppTitle      = Style{FG: 226, BG: 0, Flags: Bold + Italic}
-	styleStatusLine    = Style{FG: 230, BG: 0, Flags: Italic}
-	styleArchive       = Style{FG: 226, BG: 0, Flags: Bold}
-	styleProgressBar   = Style{FG: 231, BG: 19}
-	styleArchiveHeader = Style{FG: 231, BG: 8, Flags: Bold}
-)
-
 var (
 	rowConstraint = Constraint{Size: Size{Width: 0, Height: 1}, Flex: Flex{X: 1, Y: 0}}
 	colConstraint = Constraint{Size: Size{Width: 0, Height: 0}, Flex: Flex{X: 1, Y: 1}}
 )
 
 func (s *View) RootWidget() Widget {
-	return Styled(styleDefault,
+	root := Styled(theme.Default,
 		Column(colConstraint,
 			s.title(),
 			s.folderView(),
@@ -31,25 +22,24 @@ func (s *View) RootWidget() Widget {
 			s.fileStats(),
 		),
 	)
+	if s.Pane != nil {
+		return Overlay(root, Pane(s.Pane))
+	}
+	if s.Dialog != nil {
+		return Overlay(root, Dialog(s.Dialog.Title, s.Dialog.Lines, s.Dialog.Buttons, s.Dialog.Focused))
+	}
+	return root
 }
 
 func (c *View) title() Widget {
-	return Row(rowConstraint,
-		Styled(styleAppTitle, Text(" Archiver").Flex(1)),
-	)
+	return Styled(theme.AppTitle, Row(rowConstraint, Text(" Archiver").Flex(1)))
 }
 
 func (s *View) folderView() Widget {
 	return Column(colConstraint,
 		s.breadcrumbs(),
-		Styled(styleArchiveHeader,
-			Row(rowConstraint,
-				Text(" Status").Width(13),
-				MouseTarget(SortByName, Text(" Document"+s.sortIndicator(SortByName)).Width(20).Flex(1)),
-				MouseTarget(SortByTime, Text("  Date Modified"+s.sortIndicator(SortByTime)).Width(19)),
-				MouseTarget(SortBySize, Text(fmt.Sprintf("%22s", "Size"+s.sortIndicator(SortBySize)+" "))),
-			),
-		),
+		s.searchBar(),
+		Styled(theme.ArchiveHeader, Row(rowConstraint, s.columnHeaders()...)),
 		Scroll(m.Scroll{}, Constraint{Size: Size{Width: 0, Height: 0}, Flex: Flex{X: 1, Y: 1}},
 			func(size Size) Widget {
 				s.FileTreeLines = size.Height
@@ -74,39 +64,263 @@ func (s *View) folderView() Widget {
 				return Column(colConstraint, rows...)
 			},
 		),
+		s.detailPane(),
+	)
+}
+
+func (s *View) columnHeaders() []Widget {
+	headers := []Widget{
+		Text(" "),
+		MouseTarget(SortByState, Text(" Status"+s.sortIndicator(SortByState)).Width(13)),
+		s.nameHeader(),
+		MouseTarget(SortByCopies, Text(fmt.Sprintf("%6s", "#"+s.sortIndicator(SortByCopies)))),
+		s.presenceHeader(),
+		MouseTarget(SortByTime, Text("  Date Modified"+s.sortIndicator(SortByTime)).Width(19)),
+		MouseTarget(SortBySize, Text(fmt.Sprintf("%22s", "Size"+s.sortIndicator(SortBySize)+" "))),
+	}
+	if s.Filter != FilterAll {
+		headers = append(headers, Text(fmt.Sprintf(" Showing: %s ", s.Filter)))
+	}
+	return headers
+}
+
+func (s *View) detailPane() Widget {
+	d := s.Details
+	if d == nil {
+		return Column(Constraint{})
+	}
+	lines := []Widget{}
+	if d.Kind == FileFolder {
+		lines = append(lines,
+			Text(fmt.Sprintf(" Folder: %s", d.Name)).Flex(1),
+			Text(fmt.Sprintf(" Files: %d  Size: %s  Modified: %s",
+				d.Files, strings.TrimSpace(FormatSize(d.Size)), d.ModTime.Format(time.DateTime))).Flex(1),
+			Text(fmt.Sprintf(" Resolved: %d  Pending: %d  Duplicate: %d  Absent: %d",
+				d.States[Resolved], d.States[Pending], d.States[Duplicate], d.States[Absent])).Flex(1),
+		)
+	} else {
+		lines = append(lines,
+			Text(fmt.Sprintf(" Path: %s", d.Name)).Flex(1),
+			Text(fmt.Sprintf(" Hash: %s", d.Hash)).Flex(1),
+			Text(fmt.Sprintf(" Size: %s  Modified: %s",
+				strings.TrimSpace(FormatSize(d.Size)), d.ModTime.Format(time.DateTime))).Flex(1),
+		)
+	}
+	maxHeight := s.ScreenSize.Height / 3
+	if len(lines) >= maxHeight {
+		lines = lines[:maxHeight]
+	} else if len(d.Copies) > 0 {
+		rows := len(d.Copies)
+		if rows > maxHeight-len(lines) {
+			rows = maxHeight - len(lines)
+		}
+		lines = append(lines, Scroll(m.Scroll{Command: m.ScrollDetails{}},
+			Constraint{Size: Size{Width: 0, Height: rows}, Flex: Flex{X: 1, Y: 0}},
+			func(size Size) Widget { return Column(colConstraint, copyLines(d.Copies, d.Offset, rows)...) }))
+	}
+	height := 0
+	for _, line := range lines {
+		height += line.Constraint().Height
+	}
+	return Styled(theme.StatusLine,
+		Column(Constraint{Size: Size{Width: 0, Height: height}, Flex: Flex{X: 1, Y: 0}}, lines...),
 	)
 }
 
+// copyLines shows the copies from the offset in the rows, the last row tells
+// how many more copies there are below.
+func copyLines(copies []CopyInfo, offset, rows int) []Widget {
+	if offset > len(copies)-rows {
+		offset = len(copies) - rows
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	copies = copies[offset:]
+	more := 0
+	if len(copies) > rows {
+		more = len(copies) - rows + 1
+		copies = copies[:rows-1]
+	}
+	lines := []Widget{}
+	for _, copy := range copies {
+		line := Text(fmt.Sprintf("   %-9s %s", copy.State, copy.Id)).Flex(1)
+		if copy.State == CopyMissing {
+			lines = append(lines, line)
+		} else {
+			lines = append(lines, MouseTarget(m.SelectCopy(copy.Id), line))
+		}
+	}
+	if more > 0 {
+		lines = append(lines, Text(fmt.Sprintf("   +%d more", more)).Flex(1))
+	}
+	return lines
+}
+
 func (s *View) fileRow(file *File) []Widget {
-	result := []Widget{Text(statusString(file)).Width(11)}
+	mark := " "
+	if file.Marked {
+		mark = "●"
+	}
+	result := []Widget{Text(mark), Text(statusString(file)).Width(11)}
 
 	if file.Kind == FileRegular {
 		result = append(result, Text("   "))
 	} else {
 		result = append(result, Text(" ▶ "))
 	}
-	result = append(result, Text(file.Base.String()).Width(20).Flex(1))
+	result = append(result, s.fileName(file))
+	result = append(result, Text(copiesString(file)))
+	result = append(result, s.presenceCells(file)...)
 	result = append(result, Text("  "))
 	result = append(result, Text(file.ModTime.Format(time.DateTime)))
 	result = append(result, Text("  "))
-	result = append(result, Text(formatSize(file.Size)).Width(18))
+	result = append(result, Text(FormatSize(file.Size)).Width(18))
 	return result
 }
 
+func (s *View) nameHeader() Widget {
+	if s.FlatPaths {
+		return MouseTarget(SortByPath, Text(" Path"+s.sortIndicator(SortByPath)).Width(20).Flex(1))
+	}
+	if s.SortColumn == SortByExtension {
+		return MouseTarget(SortByName, Text(" Document by extension"+s.sortIndicator(SortByExtension)).Width(20).Flex(1))
+	}
+	return MouseTarget(SortByName, Text(" Document"+s.sortIndicator(SortByName)).Width(20).Flex(1))
+}
+
+func (s *View) rootLabels() []string {
+	labels := make([]string, len(s.Roots))
+	width := 0
+	for i, root := range s.Roots {
+		labels[i] = filepath.Base(root.String())
+		width += len([]rune(labels[i])) + 1
+	}
+	if width > s.ScreenSize.Width/4 {
+		for i := range labels {
+			labels[i] = fmt.Sprint(i)
+		}
+		labels[0] = "O"
+	}
+	return labels
+}
+
+func presenceWidth(label string) int {
+	width := len([]rune(label)) + 1
+	if width < 4 {
+		width = 4
+	}
+	return width
+}
+
+func (s *View) presenceHeader() Widget {
+	labels := s.rootLabels()
+	widgets := make([]Widget, len(labels))
+	for i, label := range labels {
+		widgets[i] = Text(fmt.Sprintf("%*s", presenceWidth(label), label))
+	}
+	width := 0
+	for _, label := range labels {
+		width += presenceWidth(label)
+	}
+	return Row(Constraint{Size: Size{Width: width, Height: 1}}, widgets...)
+}
+
+func (s *View) presenceCells(file *File) []Widget {
+	labels := s.rootLabels()
+	widgets := make([]Widget, len(labels))
+	for i, label := range labels {
+		cell := ""
+		if i < len(file.Presence) {
+			cell = presenceString(file, file.Presence[i])
+		}
+		widgets[i] = Text(fmt.Sprintf("%*s", presenceWidth(label), cell))
+	}
+	return widgets
+}
+
+func presenceString(file *File, presence Presence) string {
+	if file.Kind == FileFolder {
+		problems := presence.Missing + presence.Misnamed + presence.Duplicated
+		if problems == 0 {
+			return "✓"
+		}
+		return fmt.Sprint(problems)
+	}
+	switch {
+	case presence.Missing > 0:
+		return "-"
+	case presence.Duplicated > 0:
+		return fmt.Sprintf("×%d", presence.Duplicated+1)
+	case presence.Misnamed > 0:
+		return "~"
+	}
+	return "✓"
+}
+
+func copiesString(file *File) string {
+	if file.Kind == FileFolder {
+		return fmt.Sprintf("%6s", "")
+	}
+	return fmt.Sprintf("%6d", file.Copies)
+}
+
+func (s *View) fileName(file *File) Widget {
+	name := file.Base.String()
+	match := file.Match
+	if s.FlatPaths && file.Path != "" {
+		prefix := len([]rune(file.Path.String())) + 1
+		name = file.Name.String()
+		match = Match{Start: match.Start + prefix, End: match.End + prefix}
+	}
+	return Text(name).Width(20).Flex(1).Highlight(match)
+}
+
+func (s *View) searchBar() Widget {
+	if s.Search == nil {
+		return Column(Constraint{})
+	}
+	label := " Filter"
+	if s.Search.Recursive {
+		label = " Search all folders"
+	}
+	hint := " Tab: mode  Enter: done  Esc: cancel "
+	if s.Search.Recursive {
+		hint = " Tab: mode  Enter: jump  Esc: cancel "
+	}
+	widgets := []Widget{
+		Text(fmt.Sprintf("%s (%s): ", label, s.Search.Kind)),
+		TextInput(s.Search.Query, s.Search.Focused),
+	}
+	if s.Search.Error != "" {
+		widgets = append(widgets, Text(" "+s.Search.Error+" "))
+	} else if s.Search.Focused {
+		widgets = append(widgets, Text(hint))
+	}
+	return Styled(theme.StatusLine, Row(rowConstraint, widgets...))
+}
+
 func statusString(file *File) string {
 	switch file.State {
 	case Resolved:
 		return ""
 	case Pending:
-		return " Pending"
+		return statusSymbol("…") + " Pending"
 	case Duplicate:
-		return " Duplicate"
+		return statusSymbol("!") + " Duplicate"
 	case Absent:
-		return " Absent"
+		return statusSymbol("?") + " Absent"
 	}
 	return "UNKNOWN"
 }
 
+func statusSymbol(symbol string) string {
+	if theme.Symbols {
+		return symbol
+	}
+	return ""
+}
+
 func (s *View) sortIndicator(column SortColumn) string {
 	if column == s.SortColumn {
 		if s.SortAscending[column] {
@@ -118,16 +332,19 @@ func (s *View) sortIndicator(column SortColumn) string {
 }
 
 func (c *View) breadcrumbs() Widget {
+	if c.Problems {
+		return Row(rowConstraint, Styled(theme.Breadcrumbs, Text(" All Problems")), Spacer{})
+	}
 	names := strings.Split(c.CurrentPath.String(), "/")
 	widgets := make([]Widget, 0, len(names)*2+2)
 	widgets = append(widgets, MouseTarget(m.SelectFolder(""),
-		Styled(styleBreadcrumbs, Text(" Root")),
+		Styled(theme.Breadcrumbs, Text(" Root")),
 	))
 	for i := range names {
 		widgets = append(widgets, Text(" / "))
 		widgets = append(widgets,
 			MouseTarget(m.SelectFolder(m.Path(filepath.Join(names[:i+1]...))),
-				Styled(styleBreadcrumbs, Text(names[i])),
+				Styled(theme.Breadcrumbs, Text(names[i])),
 			),
 		)
 	}
@@ -153,25 +370,31 @@ func (s *View) progress() Widget {
 			Row(Constraint{Size: Size{Width: 0, Height: 1}, Flex: Flex{X: 1, Y: 0}},
 				Text(progress.Tab).Width(tabWidth),
 				Text(" "),
-				Styled(styleArchive, Text(progress.Root.String()).Width(rootWidth)),
+				Styled(theme.Archive, Text(progress.Root.String()).Width(rootWidth)),
 				Text(fmt.Sprintf(" %6.2f%%", progress.Value*100)),
 				Text(fmt.Sprintf(" %5.1f Mb/S", progress.Speed)),
 				Text(fmt.Sprintf(" ETA %6s", progress.TimeRemaining.Truncate(time.Second))), Text(" "),
-				Styled(styleProgressBar, ProgressBar(progress.Value)),
+				Styled(theme.ProgressBar, ProgressBar(progress.Value)),
 				Text(" "),
 			),
 		)
 	}
-	return Styled(styleStatusLine,
+	return Styled(theme.StatusLine,
 		Column(Constraint{Size: Size{Width: 0, Height: len(stats)}, Flex: Flex{X: 1, Y: 0}}, stats...),
 	)
 }
 
 func (s *View) fileStats() Widget {
-	if s.DuplicateFiles == 0 && s.AbsentFiles == 0 && s.PendingFiles == 0 {
-		return Text(" All Clear").Flex(1)
+	if s.DuplicateFiles == 0 && s.AbsentFiles == 0 && s.PendingFiles == 0 && s.MarkedFiles == 0 {
+		if s.Queue.Capacity == 0 {
+			return Text(" All Clear").Flex(1)
+		}
+		return Row(rowConstraint, Text(" All Clear").Flex(1), s.queue())
 	}
 	stats := []Widget{Text(" Stats:")}
+	if s.MarkedFiles > 0 {
+		stats = append(stats, Text(fmt.Sprintf(" Marked: %d", s.MarkedFiles)))
+	}
 	if s.DuplicateFiles > 0 {
 		stats = append(stats, Text(fmt.Sprintf(" Duplicates: %d", s.DuplicateFiles)))
 	}
@@ -182,15 +405,23 @@ func (s *View) fileStats() Widget {
 		stats = append(stats, Text(fmt.Sprintf(" Pending: %d", s.PendingFiles)))
 	}
 	stats = append(stats, Text("").Flex(1))
+	if s.Queue.Capacity > 0 {
+		stats = append(stats, s.queue())
+	}
 	stats = append(stats, Text(fmt.Sprintf(" FPS: %d ", s.FPS)))
 	return Styled(
-		styleAppTitle,
+		theme.AppTitle,
 		Row(Constraint{Size: Size{Width: 0, Height: 1}, Flex: Flex{X: 1, Y: 0}}, stats...),
 	)
 
 }
 
-func formatSize(size uint64) string {
+// queue shows how many events wait for the controller, and the most that ever waited.
+func (s *View) queue() Widget {
+	return Text(fmt.Sprintf(" Queue: %d/%d, max %d ", s.Queue.Len, s.Queue.Capacity, s.Queue.MaxLen))
+}
+
+func FormatSize(size uint64) string {
 	str := fmt.Sprintf("%13d ", size)
 	slice := []string{str[:1], str[1:4], str[4:7], str[7:10]}
 	b := strings.Builder{}
@@ -207,27 +438,15 @@ func formatSize(size uint64) string {
 }
 
 func (c *View) styleFile(file *File, selected bool) Style {
-	bg, flags := byte(17), Flags(0)
+	result := theme.File
 	if file.Kind == FileFolder {
-		bg = byte(18)
+		result = theme.Folder
 	}
-	result := Style{FG: c.statusColor(file), BG: bg, Flags: flags}
+	state := theme.stateStyle(file.State)
+	result.FG = state.FG
+	result.Flags |= state.Flags
 	if selected {
-		result.Flags |= Reverse
+		result.Flags ^= Reverse
 	}
 	return result
 }
-
-var styleBreadcrumbs = Style{FG: 250, BG: 17, Flags: Bold + Italic}
-
-func (c *View) statusColor(file *File) byte {
-	switch file.State {
-	case Resolved:
-		return 195
-	case Pending:
-		return 214
-	case Duplicate, Absent:
-		return 196
-	}
-	return 231
-}
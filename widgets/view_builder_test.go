@@ -0,0 +1,38 @@
+package widgets
+
+import (
+	m "arch/model"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetailCopiesScroll(t *testing.T) {
+	copies := []CopyInfo{}
+	for i := 0; i < 10; i++ {
+		copies = append(copies, CopyInfo{Id: m.Id{Root: m.Root(fmt.Sprintf("root %d", i)), Name: m.Name{Base: "a"}}, State: CopyPresent})
+	}
+	for _, test := range []struct {
+		offset int
+		first  string
+		last   string
+	}{
+		{0, "root 0", "+7 more"},
+		{3, "root 3", "+4 more"},
+		{9, "root 6", "root 9"},
+	} {
+		lines := copyLines(copies, test.offset, 4)
+		if len(lines) != 4 {
+			t.Fatalf("Offset %d: expected 4 lines, got %d", test.offset, len(lines))
+		}
+		if first := lines[0].String(); !strings.Contains(first, test.first) {
+			t.Errorf("Offset %d: first line %s, want %q", test.offset, first, test.first)
+		}
+		if last := lines[3].String(); !strings.Contains(last, test.last) {
+			t.Errorf("Offset %d: last line %s, want %q", test.offset, last, test.last)
+		}
+	}
+	if _, ok := copyLines(copies, 0, 4)[0].(mouseTarget); !ok {
+		t.Error("Expected the copies to be selectable")
+	}
+}
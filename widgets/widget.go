@@ -2,6 +2,7 @@ package widgets
 
 import (
 	m "arch/model"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -33,16 +34,18 @@ type Flex struct {
 }
 
 type Style struct {
-	FG, BG byte
-	Flags  Flags
+	FG    Color `json:"fg"`
+	BG    Color `json:"bg"`
+	Flags Flags `json:"flags"`
 }
 
 type Flags byte
 
 const (
-	Bold    Flags = 1
-	Italic  Flags = 2
-	Reverse Flags = 4
+	Bold      Flags = 1
+	Italic    Flags = 2
+	Reverse   Flags = 4
+	Underline Flags = 8
 )
 
 type View struct {
@@ -59,6 +62,16 @@ type View struct {
 	AbsentFiles    int
 	FileTreeLines  int
 	FPS            int
+	Queue          QueueInfo
+	Search         *SearchInfo
+	FlatPaths      bool
+	Filter         StateFilter
+	Problems       bool
+	MarkedFiles    int
+	Dialog         *DialogInfo
+	Pane           *PaneInfo
+	Roots          []m.Root
+	Details        *Details
 }
 
 func (s *View) String() string {
@@ -98,6 +111,11 @@ const (
 	SortByName SortColumn = iota
 	SortByTime
 	SortBySize
+	SortByState
+	SortByPath
+	SortByExtension
+	SortByCopies
+	SortColumns int = iota
 )
 
 func (c SortColumn) String() string {
@@ -108,6 +126,14 @@ func (c SortColumn) String() string {
 		return "SortByTime"
 	case SortBySize:
 		return "SortBySize"
+	case SortByState:
+		return "SortByState"
+	case SortByPath:
+		return "SortByPath"
+	case SortByExtension:
+		return "SortByExtension"
+	case SortByCopies:
+		return "SortByCopies"
 	}
 	return "Illegal Sort Solumn"
 }
@@ -116,6 +142,80 @@ type File struct {
 	m.File
 	Kind
 	State
+	Match
+	Marked   bool
+	Copies   int
+	Presence []Presence
+}
+
+// Presence counts how the entry's files are represented in one root.
+// For regular files the counts are 0 or 1, except Duplicated which is the number of extra copies.
+type Presence struct {
+	Present    int
+	Missing    int
+	Misnamed   int
+	Duplicated int
+}
+
+type Match struct {
+	Start, End int
+}
+
+type StateFilter int
+
+const (
+	FilterAll StateFilter = iota
+	FilterProblems
+	FilterDuplicate
+	FilterAbsent
+	FilterPending
+	FilterFailed
+)
+
+func (f StateFilter) String() string {
+	switch f {
+	case FilterAll:
+		return "All"
+	case FilterProblems:
+		return "Problems"
+	case FilterDuplicate:
+		return "Duplicates"
+	case FilterAbsent:
+		return "Absent"
+	case FilterPending:
+		return "Pending"
+	case FilterFailed:
+		return "Failed"
+	}
+	return "UNKNOWN FILTER"
+}
+
+type SearchKind int
+
+const (
+	SearchSubstring SearchKind = iota
+	SearchGlob
+	SearchRegex
+)
+
+func (k SearchKind) String() string {
+	switch k {
+	case SearchSubstring:
+		return "substring"
+	case SearchGlob:
+		return "glob"
+	case SearchRegex:
+		return "regex"
+	}
+	return "UNKNOWN SEARCH KIND"
+}
+
+type SearchInfo struct {
+	Query     string
+	Kind      SearchKind
+	Recursive bool
+	Focused   bool
+	Error     string
 }
 
 type Kind int
@@ -134,6 +234,56 @@ const (
 	Absent
 )
 
+type DialogInfo struct {
+	Title   string
+	Lines   []string
+	Buttons []string
+	Focused int
+}
+
+type Details struct {
+	Name    m.Name
+	Kind    Kind
+	Hash    m.Hash
+	Size    uint64
+	ModTime time.Time
+	Copies  []CopyInfo
+	Offset  int
+	Files   int
+	States  [Absent + 1]int
+}
+
+type CopyInfo struct {
+	Id    m.Id
+	State CopyState
+}
+
+type CopyState int
+
+const (
+	CopySelected CopyState = iota
+	CopyPresent
+	CopyMisnamed
+	CopyDuplicate
+	CopyMissing
+)
+
+func (s CopyState) String() string {
+	switch s {
+	case CopySelected:
+		return "Selected"
+	case CopyPresent:
+		return "Present"
+	case CopyMisnamed:
+		return "Misnamed"
+	case CopyDuplicate:
+		return "Duplicate"
+	case CopyMissing:
+		return "Missing"
+	}
+	return "UNKNOWN COPY STATE"
+}
+
 type ProgressInfo struct {
 	Root          m.Root
 	Tab           string
@@ -142,6 +292,13 @@ type ProgressInfo struct {
 	TimeRemaining time.Duration
 }
 
+// QueueInfo is the depth of the event queue. Unbounded queues have zero Capacity.
+type QueueInfo struct {
+	Len      int
+	MaxLen   int
+	Capacity int
+}
+
 func (f *File) String() string {
 	return fmt.Sprintf("File{FileId: %q, Kind: %s, Size: %d, Hash: %q, State: %s}", f.Id, f.Kind, f.Size, f.Hash, f.State)
 }
@@ -171,7 +328,7 @@ func (p State) String() string {
 }
 
 func (s Style) String() string {
-	return fmt.Sprintf("Style{FG: %d, BG: %d, Flags: {%s}", s.FG, s.BG, s.Flags)
+	return fmt.Sprintf("Style{FG: %s, BG: %s, Flags: {%s}", s.FG, s.BG, s.Flags)
 }
 
 func (c Constraint) String() string {
@@ -189,9 +346,35 @@ func (f Flags) String() string {
 	if f&Reverse == Reverse {
 		flags = append(flags, "Reverse")
 	}
+	if f&Underline == Underline {
+		flags = append(flags, "Underline")
+	}
 	return strings.Join(flags, ", ")
 }
 
+var flagNames = map[string]Flags{
+	"bold":      Bold,
+	"italic":    Italic,
+	"reverse":   Reverse,
+	"underline": Underline,
+}
+
+func (f *Flags) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*f = 0
+	for _, name := range names {
+		flag, ok := flagNames[name]
+		if !ok {
+			return fmt.Errorf("invalid style flag %q", name)
+		}
+		*f |= flag
+	}
+	return nil
+}
+
 func toString[W Widget](w W) string {
 	buf := &strings.Builder{}
 	w.ToString(buf, "")